@@ -0,0 +1,249 @@
+// Package check finds broken links in documents stored in a repository.
+package check
+
+import (
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/tdewolff/parse/v2"
+
+	"github.com/martin-sucha/site-to-static/repository"
+	"github.com/martin-sucha/site-to-static/rewrite"
+)
+
+// ProblemKind describes what is wrong with a link.
+type ProblemKind string
+
+const (
+	// ProblemBroken is a link to a URL that returned 4xx or 5xx status code.
+	ProblemBroken ProblemKind = "broken"
+	// ProblemUnfetched is a link to a URL that is not stored in the repository, usually because it is out of scope.
+	ProblemUnfetched ProblemKind = "unfetched"
+	// ProblemFailed is a link to a URL that could not be fetched, see repository.Failure.
+	ProblemFailed ProblemKind = "failed"
+	// ProblemRedirect is a link to a URL that redirects. The redirect target is fine.
+	ProblemRedirect ProblemKind = "redirect"
+)
+
+// Problem is a single problematic link.
+type Problem struct {
+	Kind ProblemKind
+	// URL is the absolute link target.
+	URL string
+	// StatusCode is the status code of the final URL after following redirects. Zero if not stored.
+	StatusCode int `json:",omitempty"`
+	// Redirects are the URLs the link redirects to, in order.
+	Redirects []string `json:",omitempty"`
+	// Error is the error of a failed fetch.
+	Error string `json:",omitempty"`
+}
+
+// Page contains problems of links in a single document.
+type Page struct {
+	URL      string
+	Problems []Problem
+}
+
+// Report contains all pages with problematic links, sorted by URL.
+type Report struct {
+	Pages []Page
+}
+
+// Options configure Run.
+type Options struct {
+	// IgnoreRedirects does not report links to redirects if the redirect target is fine.
+	IgnoreRedirects bool
+	// Rewrite configures discovery of links in documents. nil means defaults.
+	Rewrite *rewrite.Options
+}
+
+// maxRedirects limits the length of redirect chains that are followed.
+const maxRedirects = 10
+
+// stored is a document stored in the repository.
+type stored struct {
+	url        string
+	statusCode int
+	location   string
+}
+
+// Run checks links in all documents stored in repo.
+func Run(repo *repository.Repository, opts Options) (*Report, error) {
+	entries, err := repo.List()
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[string]stored, len(entries))
+	for _, e := range entries {
+		doc, err := e.Open()
+		if err != nil {
+			return nil, err
+		}
+		docs[doc.Metadata.Key] = stored{
+			url:        doc.Metadata.URL,
+			statusCode: doc.Metadata.StatusCode,
+			location:   doc.Metadata.Headers.Get("Location"),
+		}
+		err = doc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	failureList, err := repo.ListFailures()
+	if err != nil {
+		return nil, err
+	}
+	failures := make(map[string]*repository.Failure, len(failureList))
+	for _, failure := range failureList {
+		failures[failure.Key] = failure
+	}
+
+	c := &checker{
+		docs:     docs,
+		failures: failures,
+		opts:     opts,
+	}
+	report := &Report{}
+	for _, e := range entries {
+		page, err := c.checkEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		if page != nil && len(page.Problems) > 0 {
+			report.Pages = append(report.Pages, *page)
+		}
+	}
+	sort.Slice(report.Pages, func(i, j int) bool {
+		return report.Pages[i].URL < report.Pages[j].URL
+	})
+	return report, nil
+}
+
+type checker struct {
+	docs     map[string]stored
+	failures map[string]*repository.Failure
+	opts     Options
+}
+
+// checkEntry checks links in a single document. Returns nil if the document is not a page with links.
+func (c *checker) checkEntry(e repository.Entry) (outPage *Page, outErr error) {
+	doc, err := e.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		closeErr := doc.Close()
+		if outErr == nil {
+			outErr = closeErr
+		}
+	}()
+	if doc.Metadata.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+	mediaType, mediaParams, err := mime.ParseMediaType(doc.Metadata.Headers.Get("content-type"))
+	if err != nil || !rewrite.IsSupportedMediaType(mediaType, mediaParams) {
+		return nil, nil
+	}
+	docURL, err := url.Parse(doc.Metadata.URL)
+	if err != nil {
+		return nil, err
+	}
+	page := &Page{URL: doc.Metadata.URL}
+	seen := make(map[string]struct{})
+	rewriter := func(u rewrite.URL) (string, error) {
+		if !u.IsFetchable() {
+			return "", rewrite.ErrNotModified
+		}
+		baseURL := docURL
+		if u.Base != "" {
+			var err error
+			baseURL, err = url.Parse(u.Base)
+			if err != nil {
+				return "", fmt.Errorf("parsing base url in document %q: %v", docURL.String(), err)
+			}
+		}
+		referenceURL, err := url.Parse(strings.TrimSpace(u.Value))
+		if err != nil {
+			page.Problems = append(page.Problems, Problem{
+				Kind:  ProblemBroken,
+				URL:   u.Value,
+				Error: err.Error(),
+			})
+			return "", rewrite.ErrNotModified
+		}
+		targetURL := baseURL.ResolveReference(referenceURL)
+		if targetURL.Scheme != "http" && targetURL.Scheme != "https" {
+			return "", rewrite.ErrNotModified
+		}
+		key := repository.Key(targetURL)
+		if _, ok := seen[key]; ok {
+			return "", rewrite.ErrNotModified
+		}
+		seen[key] = struct{}{}
+		if problem := c.checkLink(targetURL); problem != nil {
+			page.Problems = append(page.Problems, *problem)
+		}
+		return "", rewrite.ErrNotModified
+	}
+	data, err := ioutil.ReadAll(doc.Body())
+	if err != nil {
+		return nil, err
+	}
+	err = rewrite.Document(mediaType, mediaParams, parse.NewInputBytes(data), ioutil.Discard, rewriter, c.opts.Rewrite)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", doc.Metadata.URL, err)
+	}
+	return page, nil
+}
+
+// checkLink returns the problem of link to targetURL, following redirects. Returns nil if the link is fine.
+func (c *checker) checkLink(targetURL *url.URL) *Problem {
+	problem := &Problem{URL: targetURL.String()}
+	current := targetURL
+	for {
+		key := repository.Key(current)
+		doc, ok := c.docs[key]
+		if !ok {
+			if failure, ok := c.failures[key]; ok {
+				problem.Kind = ProblemFailed
+				problem.Error = failure.Error
+				return problem
+			}
+			problem.Kind = ProblemUnfetched
+			return problem
+		}
+		problem.StatusCode = doc.statusCode
+		switch {
+		case 400 <= doc.statusCode && doc.statusCode <= 599:
+			problem.Kind = ProblemBroken
+			return problem
+		case 300 <= doc.statusCode && doc.statusCode <= 399 && doc.location != "":
+			if len(problem.Redirects) >= maxRedirects {
+				problem.Kind = ProblemBroken
+				problem.Error = fmt.Sprintf("stopped after %d redirects", maxRedirects)
+				return problem
+			}
+			locationURL, err := url.Parse(doc.location)
+			if err != nil {
+				problem.Kind = ProblemBroken
+				problem.Error = err.Error()
+				return problem
+			}
+			current = current.ResolveReference(locationURL)
+			problem.Redirects = append(problem.Redirects, current.String())
+		case len(problem.Redirects) > 0:
+			if c.opts.IgnoreRedirects {
+				return nil
+			}
+			problem.Kind = ProblemRedirect
+			return problem
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package check
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/martin-sucha/site-to-static/repository"
+)
+
+func storeDocument(t *testing.T, repo *repository.Repository, u string, statusCode int, headers http.Header,
+	body string) {
+	dw, err := repo.NewWriter()
+	require.NoError(t, err)
+	_, err = dw.Write([]byte(body))
+	require.NoError(t, err)
+	require.NoError(t, dw.Close(&repository.DocumentMetadata{
+		Key:        u,
+		URL:        u,
+		StatusCode: statusCode,
+		Headers:    headers,
+	}))
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	repo := repository.New(dir)
+
+	html := http.Header{"Content-Type": []string{"text/html; charset=utf-8"}}
+	storeDocument(t, repo, "http://example.com/", 200, html,
+		`<a href="/ok">ok</a><a href="/missing">missing</a><a href="/missing">again</a>`+
+			`<a href="/old">old</a><a href="http://other.example.com/">other</a><a href="mailto:a@example.com">`+
+			`<img src="/down.png">`)
+	storeDocument(t, repo, "http://example.com/ok", 200, html, `<a href="/">home</a>`)
+	storeDocument(t, repo, "http://example.com/missing", 404, html, `not found`)
+	storeDocument(t, repo, "http://example.com/old", 301, http.Header{"Location": []string{"/old/"}}, ``)
+	storeDocument(t, repo, "http://example.com/old/", 302, http.Header{"Location": []string{"/ok"}}, ``)
+	require.NoError(t, repo.StoreFailure(&repository.Failure{
+		Key:      "http://example.com/down.png",
+		URL:      "http://example.com/down.png",
+		Error:    "connection refused",
+		Time:     time.Now(),
+		Attempts: 1,
+	}))
+
+	report, err := Run(repo, Options{})
+	require.NoError(t, err)
+	require.Equal(t, &Report{
+		Pages: []Page{
+			{
+				URL: "http://example.com/",
+				Problems: []Problem{
+					{Kind: ProblemBroken, URL: "http://example.com/missing", StatusCode: 404},
+					{Kind: ProblemRedirect, URL: "http://example.com/old", StatusCode: 200,
+						Redirects: []string{"http://example.com/old/", "http://example.com/ok"}},
+					{Kind: ProblemUnfetched, URL: "http://other.example.com/"},
+					{Kind: ProblemFailed, URL: "http://example.com/down.png", Error: "connection refused"},
+				},
+			},
+		},
+	}, report)
+
+	report, err = Run(repo, Options{IgnoreRedirects: true})
+	require.NoError(t, err)
+	require.Len(t, report.Pages, 1)
+	require.Len(t, report.Pages[0].Problems, 3)
+}
@@ -21,7 +21,20 @@ import (
 	"github.com/martin-sucha/site-to-static/urlnorm"
 )
 
-func Generate(repo *repository.Repository, outDir string, urlRewriter rewrite.URLRewriter) error {
+// Options configure generating of files.
+type Options struct {
+	// URLRewriter returns the rewriter of URLs in the document downloaded from docURL.
+	// nil keeps the URLs as they are.
+	URLRewriter func(docURL *url.URL) rewrite.URLRewriter
+	// Rewrite configures discovery of URLs in documents. nil means defaults.
+	Rewrite *rewrite.Options
+	// RelativeLinks rewrites links to documents stored in the repository to relative paths of the generated files,
+	// so that the output can be browsed without a web server. Other links are passed to URLRewriter.
+	RelativeLinks bool
+}
+
+// Generate writes documents stored in repo to outDir.
+func Generate(repo *repository.Repository, outDir string, opts Options) error {
 	entries, err := repo.List()
 	if err != nil {
 		return err
@@ -30,26 +43,49 @@ func Generate(repo *repository.Repository, outDir string, urlRewriter rewrite.UR
 	if err != nil {
 		return err
 	}
+	g := &generator{
+		outDir:  outDir,
+		opts:    opts,
+		headers: newHeaderConfig(),
+	}
+	if opts.RelativeLinks {
+		g.index, err = newFileIndex(entries)
+		if err != nil {
+			return err
+		}
+	}
 	var errorCount int64
 	for _, e := range entries {
-		err = generateEntry(e, outDir, urlRewriter)
+		err = g.generateEntry(e)
 		if err != nil {
 			_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
 			errorCount++
 		}
 	}
+	err = g.headers.write(outDir)
+	if err != nil {
+		return err
+	}
 	if errorCount > 0 {
 		return fmt.Errorf("%d entries were skipped because of errors", errorCount)
 	}
 	return nil
 }
 
-func generateEntry(e repository.Entry, outDir string, urlRewriter rewrite.URLRewriter) error {
+type generator struct {
+	outDir string
+	opts   Options
+	// index is used to find generated files for relative links, nil if RelativeLinks is false.
+	index   *fileIndex
+	headers *headerConfig
+}
+
+func (g *generator) generateEntry(e repository.Entry) error {
 	doc, err := e.Open()
 	if err != nil {
 		return err
 	}
-	err = processEntry(doc, outDir, urlRewriter)
+	err = g.processEntry(doc)
 	closeErr := doc.Close()
 	if err != nil {
 		return err
@@ -60,37 +96,64 @@ func generateEntry(e repository.Entry, outDir string, urlRewriter rewrite.URLRew
 	return nil
 }
 
-func processEntry(doc *repository.Document, outDir string, urlRewriter rewrite.URLRewriter) error {
+// outputPath returns the name of the site directory and the slash-separated path of the generated file in it
+// for a document downloaded from u with the media type.
+func outputPath(u *url.URL, mediaType string) (siteDir, filename string) {
+	uc := urlnorm.Canonical(u)
+	siteDir = fmt.Sprintf("%s-%s-%s", uc.Scheme, uc.Hostname(), resolvePort(uc.Scheme, uc.Port()))
+	filename = u.Path
+	if u.RawQuery != "" {
+		filename += "?" + u.RawQuery
+	} else if strings.HasSuffix(u.Path, "/") || u.Path == "" {
+		filename += "index"
+	}
+	if mediaType == "text/html" && !htmlExtensionRe.MatchString(filename) {
+		filename += ".html"
+	}
+	return siteDir, filename
+}
+
+// urlRewriter returns the rewriter for URLs in document downloaded from docURL with the media type
+// and generated to siteDir/filename.
+func (g *generator) urlRewriter(docURL *url.URL, mediaType, siteDir, filename string) rewrite.URLRewriter {
+	var urlRewriter rewrite.URLRewriter
+	if g.opts.URLRewriter != nil {
+		urlRewriter = g.opts.URLRewriter(docURL)
+	}
+	if g.index == nil {
+		return urlRewriter
+	}
+	rl := &relativeLinker{
+		index:      g.index,
+		docURL:     docURL,
+		sourcePath: siteDir + "/" + strings.TrimPrefix(filename, "/"),
+		html:       mediaType == "text/html",
+		fallback:   urlRewriter,
+	}
+	return rl.rewriteURL
+}
+
+func (g *generator) processEntry(doc *repository.Document) error {
 	u, err := url.Parse(doc.Metadata.URL)
 	if err != nil {
 		return err
 	}
-	uc := urlnorm.Canonical(u)
 	switch {
 	case doc.Metadata.StatusCode == 404:
 		// skip
 		return nil
 	case doc.Metadata.StatusCode == 200:
-		dir := fmt.Sprintf("%s-%s-%s", uc.Scheme, uc.Hostname(), resolvePort(uc.Scheme, uc.Port()))
-		err := os.MkdirAll(filepath.Join(outDir, dir), 0777)
+		mediaType, mediaParams, err := mime.ParseMediaType(doc.Metadata.Headers.Get("content-type"))
 		if err != nil {
 			return err
 		}
-		mediaType, mediaParams, err := mime.ParseMediaType(doc.Metadata.Headers.Get("content-type"))
+		siteDir, filename := outputPath(u, mediaType)
+		err = os.MkdirAll(filepath.Join(g.outDir, siteDir), 0777)
 		if err != nil {
 			return err
 		}
-		filename := u.Path
-		if u.RawQuery != "" {
-			filename += "?" + u.RawQuery
-		} else if strings.HasSuffix(u.Path, "/") || u.Path == "" {
-			filename += "index"
-		}
-		if mediaType == "text/html" && !htmlExtensionRe.MatchString(filename) {
-			filename += ".html"
-		}
-		outputPath := filepath.Join(outDir, dir, filename)
-		dir, _ = filepath.Split(outputPath)
+		outputPath := filepath.Join(g.outDir, siteDir, filename)
+		dir, _ := filepath.Split(outputPath)
 		err = os.MkdirAll(dir, 0777)
 		if err != nil {
 			return err
@@ -99,10 +162,12 @@ func processEntry(doc *repository.Document, outDir string, urlRewriter rewrite.U
 		if err != nil {
 			return err
 		}
+		urlRewriter := g.urlRewriter(u, mediaType, siteDir, filename)
 		if urlRewriter == nil || !rewrite.IsSupportedMediaType(mediaType, mediaParams) {
 			_, err = io.Copy(f, doc.Body())
 		} else {
-			err = rewrite.Document(mediaType, mediaParams, parse.NewInput(doc.Body()), f, urlRewriter)
+			err = rewrite.Document(mediaType, mediaParams, parse.NewInput(doc.Body()), f, urlRewriter,
+				g.opts.Rewrite)
 		}
 
 		closeErr := f.Close()
@@ -112,6 +177,10 @@ func processEntry(doc *repository.Document, outDir string, urlRewriter rewrite.U
 		if closeErr != nil {
 			return closeErr
 		}
+		err = g.headers.add(siteDir, u, doc.Metadata.Headers, urlRewriter)
+		if err != nil {
+			return err
+		}
 		mtime := doc.Metadata.DownloadStartedTime
 		if lastModified := doc.Metadata.Headers.Get("Last-Modified"); lastModified != "" {
 			parsedTime, err := http.ParseTime(lastModified)
@@ -0,0 +1,93 @@
+package files
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/martin-sucha/site-to-static/rewrite"
+)
+
+// headersFilename is the name of the file with response headers in each site directory.
+// The format is understood by Netlify and Cloudflare Pages.
+const headersFilename = "_headers"
+
+// preservedHeaders are response headers written to the headers file.
+// They reference other resources, so they are needed to serve the site the same way.
+var preservedHeaders = []string{"Link", "Refresh", "Content-Location"}
+
+// headerConfig collects preserved headers of generated files.
+type headerConfig struct {
+	// sites maps site directory to headers of files in it.
+	sites map[string][]fileHeaders
+}
+
+type fileHeaders struct {
+	// path is the escaped URL path the file is served at.
+	path   string
+	header http.Header
+}
+
+func newHeaderConfig() *headerConfig {
+	return &headerConfig{sites: make(map[string][]fileHeaders)}
+}
+
+// add records preserved headers of the document downloaded from u and generated to siteDir,
+// rewriting URLs in them with urlRewriter.
+//
+// Rules in the headers file apply to the path of the request, not the generated filename.
+// Documents with a query are skipped, as rules can't match a query.
+func (hc *headerConfig) add(siteDir string, u *url.URL, header http.Header, urlRewriter rewrite.URLRewriter) error {
+	if u.RawQuery != "" {
+		return nil
+	}
+	preserved := make(http.Header)
+	for _, name := range preservedHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			preserved[name] = values
+		}
+	}
+	if len(preserved) == 0 {
+		return nil
+	}
+	if urlRewriter != nil {
+		var err error
+		preserved, err = rewrite.Headers(preserved, urlRewriter)
+		if err != nil {
+			return err
+		}
+	}
+	hc.sites[siteDir] = append(hc.sites[siteDir], fileHeaders{
+		path:   "/" + strings.TrimPrefix(u.EscapedPath(), "/"),
+		header: preserved,
+	})
+	return nil
+}
+
+// write writes headers file to each site directory under outDir.
+func (hc *headerConfig) write(outDir string) error {
+	for siteDir, files := range hc.sites {
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].path < files[j].path
+		})
+		var buf bytes.Buffer
+		for _, f := range files {
+			_, _ = fmt.Fprintf(&buf, "%s\n", f.path)
+			for _, name := range preservedHeaders {
+				for _, value := range f.header[name] {
+					_, _ = fmt.Fprintf(&buf, "  %s: %s\n", name, value)
+				}
+			}
+		}
+		err := ioutil.WriteFile(filepath.Join(outDir, siteDir, headersFilename), buf.Bytes(), 0666)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
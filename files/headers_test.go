@@ -0,0 +1,82 @@
+package files
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/martin-sucha/site-to-static/rewrite"
+)
+
+func TestHeaderConfig(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "files-test-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(outDir)
+	}()
+	hc := newHeaderConfig()
+	urlRewriter := func(u rewrite.URL) (string, error) {
+		if strings.HasPrefix(u.Value, "http://example.com/") {
+			return "https://example.org/" + strings.TrimPrefix(u.Value, "http://example.com/"), nil
+		}
+		return "", rewrite.ErrNotModified
+	}
+	require.NoError(t, hc.add("http-example.com-80", mustParseURL(t, "http://example.com/about"), http.Header{
+		"Content-Type": []string{"text/html"},
+		"Link":         []string{`<http://example.com/style.css>; rel=preload, </script.js>; rel=preload`},
+		"Refresh":      []string{"5; url=http://example.com/"},
+	}, urlRewriter))
+	require.NoError(t, hc.add("http-example.com-80", mustParseURL(t, "http://example.com"), http.Header{
+		"Content-Location": []string{"/index.en.html"},
+	}, nil))
+	require.NoError(t, hc.add("http-example.com-80", mustParseURL(t, "http://example.com/a%20b/"), http.Header{
+		"Link": []string{`</a.css>; rel=preload`},
+	}, urlRewriter))
+	// Rules can't match the query.
+	require.NoError(t, hc.add("http-example.com-80", mustParseURL(t, "http://example.com/page?x=1"), http.Header{
+		"Link": []string{`</page.css>; rel=preload`},
+	}, nil))
+	// Sites without preserved headers have no headers file.
+	require.NoError(t, hc.add("http-other.example.com-80", mustParseURL(t, "http://other.example.com/"),
+		http.Header{"Content-Type": []string{"text/html"}}, nil))
+	for _, siteDir := range []string{"http-example.com-80", "http-other.example.com-80"} {
+		require.NoError(t, os.Mkdir(filepath.Join(outDir, siteDir), 0777))
+	}
+
+	require.NoError(t, hc.write(outDir))
+
+	data, err := ioutil.ReadFile(filepath.Join(outDir, "http-example.com-80", headersFilename))
+	require.NoError(t, err)
+	assert.Equal(t, `/
+  Content-Location: /index.en.html
+/a%20b/
+  Link: </a.css>; rel=preload
+/about
+  Link: <https://example.org/style.css>; rel=preload, </script.js>; rel=preload
+  Refresh: 5; url=https://example.org/
+`, string(data))
+	_, err = os.Stat(filepath.Join(outDir, "http-other.example.com-80", headersFilename))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestGenerate_Headers(t *testing.T) {
+	repo, dir := newTestRepository(t)
+	storeDocument(t, repo, "http://example.com/", 200, http.Header{
+		"Content-Type": []string{"text/html"},
+		"Link":         []string{`</style.css>; rel=preload; as=style`},
+	}, "")
+	storeDocument(t, repo, "http://example.com/style.css", 200, cssHeader, "")
+	outDir := filepath.Join(dir, "out")
+
+	require.NoError(t, Generate(repo, outDir, Options{RelativeLinks: true}))
+
+	data, err := ioutil.ReadFile(filepath.Join(outDir, "http-example.com-80", headersFilename))
+	require.NoError(t, err)
+	assert.Equal(t, "/\n  Link: <style.css>; rel=preload; as=style\n", string(data))
+}
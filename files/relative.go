@@ -0,0 +1,161 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/martin-sucha/site-to-static/repository"
+	"github.com/martin-sucha/site-to-static/rewrite"
+	"github.com/martin-sucha/site-to-static/urlrebase"
+)
+
+// maxRedirects is the maximum number of redirects followed when looking up a generated file.
+const maxRedirects = 10
+
+// fileIndex maps repository keys to paths of generated files.
+type fileIndex struct {
+	// files maps keys of documents with status 200 to slash-separated paths of generated files
+	// relative to the output directory.
+	files map[string]string
+	// redirects maps keys of redirect documents to the resolved redirect targets.
+	redirects map[string]*url.URL
+}
+
+// newFileIndex reads metadata of entries and returns the index of files that Generate writes for them.
+func newFileIndex(entries []repository.Entry) (*fileIndex, error) {
+	idx := &fileIndex{
+		files:     make(map[string]string),
+		redirects: make(map[string]*url.URL),
+	}
+	for _, e := range entries {
+		doc, err := e.Open()
+		if err != nil {
+			return nil, err
+		}
+		metadata := doc.Metadata
+		err = doc.Close()
+		if err != nil {
+			return nil, err
+		}
+		u, err := url.Parse(metadata.URL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing url %q: %v", metadata.URL, err)
+		}
+		switch {
+		case metadata.StatusCode == 200:
+			mediaType, _, err := mime.ParseMediaType(metadata.Headers.Get("content-type"))
+			if err != nil {
+				// processEntry reports the error.
+				continue
+			}
+			siteDir, filename := outputPath(u, mediaType)
+			idx.files[repository.Key(u)] = siteDir + "/" + strings.TrimPrefix(filename, "/")
+		case 300 <= metadata.StatusCode && metadata.StatusCode <= 399:
+			location, err := url.Parse(strings.TrimSpace(metadata.Headers.Get("Location")))
+			if err != nil {
+				continue
+			}
+			idx.redirects[repository.Key(u)] = u.ResolveReference(location)
+		}
+	}
+	return idx, nil
+}
+
+// lookup returns the path of the generated file for u, following redirects.
+func (idx *fileIndex) lookup(u *url.URL) (string, bool) {
+	for i := 0; i <= maxRedirects; i++ {
+		key := repository.Key(u)
+		if p, ok := idx.files[key]; ok {
+			return p, true
+		}
+		target, ok := idx.redirects[key]
+		if !ok {
+			return "", false
+		}
+		u = target
+	}
+	return "", false
+}
+
+// relativeLinker rewrites links in a document to relative paths of generated files.
+type relativeLinker struct {
+	index *fileIndex
+	// docURL is the URL the document was downloaded from.
+	docURL *url.URL
+	// sourcePath is the slash-separated path of the file generated for the document, relative to the output
+	// directory.
+	sourcePath string
+	// html is true if the document is HTML. Strings in JavaScript files are resolved against the HTML document
+	// running the script, which is not known, so they are not rewritten to relative paths.
+	html bool
+	// fallback rewrites links that are not in the index, nil keeps them.
+	fallback rewrite.URLRewriter
+}
+
+// rewriteURL returns u rewritten to a path of the generated file relative to sourcePath.
+//
+// Links that are not in the index are passed to fallback with the resolved absolute URL as the value if fallback
+// is not nil. Relative links that fallback does not modify are made absolute, so that they point to the original
+// site.
+func (rl *relativeLinker) rewriteURL(u rewrite.URL) (string, error) {
+	if !u.IsFetchable() {
+		return "", rewrite.ErrNotModified
+	}
+	value := strings.TrimSpace(u.Value)
+	if u.Type == rewrite.URLTypeBase {
+		// Relative links are resolved against the generated file itself.
+		return "./" + url.PathEscape(path.Base(rl.sourcePath)), nil
+	}
+	if value == "" || strings.HasPrefix(value, "#") {
+		return "", rewrite.ErrNotModified
+	}
+	baseURL := rl.docURL
+	if u.Base != "" {
+		parsedBase, err := url.Parse(strings.TrimSpace(u.Base))
+		if err != nil {
+			return "", fmt.Errorf("parsing base url in document %q: %v", rl.docURL.String(), err)
+		}
+		baseURL = rl.docURL.ResolveReference(parsedBase)
+	}
+	reference, err := url.Parse(value)
+	if err != nil {
+		return "", rewrite.ErrNotModified
+	}
+	targetURL := baseURL.ResolveReference(reference)
+	if targetURL.Scheme != "http" && targetURL.Scheme != "https" {
+		return "", rewrite.ErrNotModified
+	}
+	var targetPath string
+	ok := false
+	if rl.html || u.Type != rewrite.URLTypeJSString {
+		targetPath, ok = rl.index.lookup(targetURL)
+	}
+	if !ok {
+		if rl.fallback != nil {
+			fallbackURL := u
+			fallbackURL.Value = targetURL.String()
+			newURL, err := rl.fallback(fallbackURL)
+			if !errors.Is(err, rewrite.ErrNotModified) {
+				return newURL, err
+			}
+		}
+		if reference.IsAbs() {
+			return "", rewrite.ErrNotModified
+		}
+		return targetURL.String(), nil
+	}
+	// url.URL escapes ? in the path, the query is part of the filename.
+	link := (&url.URL{Path: urlrebase.RelativePath(rl.sourcePath, targetPath)}).String()
+	if u.Type == rewrite.URLTypeJSImport && !strings.HasPrefix(link, "./") && !strings.HasPrefix(link, "../") {
+		// Module specifiers must start with ./ or ../ to be treated as URLs.
+		link = "./" + link
+	}
+	if reference.Fragment != "" {
+		link += "#" + reference.EscapedFragment()
+	}
+	return link, nil
+}
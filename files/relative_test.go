@@ -0,0 +1,220 @@
+package files
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/martin-sucha/site-to-static/repository"
+	"github.com/martin-sucha/site-to-static/rewrite"
+)
+
+func storeDocument(t *testing.T, repo *repository.Repository, u string, statusCode int, headers http.Header,
+	body string) {
+	parsedURL, err := url.Parse(u)
+	require.NoError(t, err)
+	dw, err := repo.NewWriter()
+	require.NoError(t, err)
+	_, err = dw.Write([]byte(body))
+	require.NoError(t, err)
+	require.NoError(t, dw.Close(&repository.DocumentMetadata{
+		Key:        repository.Key(parsedURL),
+		URL:        u,
+		StatusCode: statusCode,
+		Headers:    headers,
+	}))
+}
+
+func newTestRepository(t *testing.T) (*repository.Repository, string) {
+	dir, err := ioutil.TempDir("", "files-test-")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return repository.New(dir), dir
+}
+
+func mustParseURL(t *testing.T, s string) *url.URL {
+	u, err := url.Parse(s)
+	require.NoError(t, err)
+	return u
+}
+
+var (
+	htmlHeader = http.Header{"Content-Type": []string{"text/html; charset=utf-8"}}
+	cssHeader  = http.Header{"Content-Type": []string{"text/css"}}
+	jsHeader   = http.Header{"Content-Type": []string{"text/javascript"}}
+	pngHeader  = http.Header{"Content-Type": []string{"image/png"}}
+)
+
+func redirectHeader(location string) http.Header {
+	return http.Header{"Location": []string{location}}
+}
+
+func TestOutputPath(t *testing.T) {
+	tests := []struct {
+		u, mediaType, siteDir, filename string
+	}{
+		{"http://example.com/", "text/html", "http-example.com-80", "/index.html"},
+		{"http://example.com", "text/html", "http-example.com-80", "index.html"},
+		{"HTTPS://Example.com:8443/a/", "text/html", "https-example.com-8443", "/a/index.html"},
+		{"http://example.com/about", "text/html", "http-example.com-80", "/about.html"},
+		{"http://example.com/page.htm", "text/html", "http-example.com-80", "/page.htm"},
+		{"http://example.com/file.aspx", "text/html", "http-example.com-80", "/file.aspx.html"},
+		{"http://example.com/page?x=1", "text/html", "http-example.com-80", "/page?x=1.html"},
+		{"http://example.com/a/?x=1", "text/css", "http-example.com-80", "/a/?x=1"},
+		{"https://example.com/img.png", "image/png", "https-example.com-443", "/img.png"},
+	}
+	for _, test := range tests {
+		siteDir, filename := outputPath(mustParseURL(t, test.u), test.mediaType)
+		assert.Equal(t, test.siteDir, siteDir, test.u)
+		assert.Equal(t, test.filename, filename, test.u)
+	}
+}
+
+func newTestIndex(t *testing.T) *fileIndex {
+	repo, _ := newTestRepository(t)
+	storeDocument(t, repo, "http://example.com/", 200, htmlHeader, "")
+	storeDocument(t, repo, "http://example.com/blog/post", 200, htmlHeader, "")
+	storeDocument(t, repo, "http://example.com/page?x=1&a=2", 200, htmlHeader, "")
+	storeDocument(t, repo, "http://example.com/a:b", 200, htmlHeader, "")
+	storeDocument(t, repo, "http://example.com/static/app.js", 200, jsHeader, "")
+	storeDocument(t, repo, "http://example.com/static/style.css", 200, cssHeader, "")
+	storeDocument(t, repo, "http://example.com/img/a b.png", 200, pngHeader, "")
+	storeDocument(t, repo, "http://other.example.com/", 200, htmlHeader, "")
+	storeDocument(t, repo, "http://example.com/docs", 301, redirectHeader("http://example.com/docs/"), "")
+	storeDocument(t, repo, "http://example.com/docs/", 302, redirectHeader("/blog/post"), "")
+	storeDocument(t, repo, "http://example.com/loop", 302, redirectHeader("/loop"), "")
+	storeDocument(t, repo, "http://example.com/missing", 404, htmlHeader, "")
+	entries, err := repo.List()
+	require.NoError(t, err)
+	idx, err := newFileIndex(entries)
+	require.NoError(t, err)
+	return idx
+}
+
+func TestFileIndex_Lookup(t *testing.T) {
+	idx := newTestIndex(t)
+	tests := []struct {
+		u, expected string
+	}{
+		{"http://example.com/", "http-example.com-80/index.html"},
+		{"http://EXAMPLE.com:80", "http-example.com-80/index.html"},
+		{"http://example.com/blog/post#comments", "http-example.com-80/blog/post.html"},
+		// Documents are found by repository key, the filename keeps the original query.
+		{"http://example.com/page?a=2&x=1&utm_source=feed", "http-example.com-80/page?x=1&a=2.html"},
+		// Redirects with absolute and relative Location are followed.
+		{"http://example.com/docs", "http-example.com-80/blog/post.html"},
+		{"http://example.com/loop", ""},
+		{"http://example.com/missing", ""},
+		{"http://example.com/unknown", ""},
+	}
+	for _, test := range tests {
+		p, ok := idx.lookup(mustParseURL(t, test.u))
+		assert.Equal(t, test.expected != "", ok, test.u)
+		assert.Equal(t, test.expected, p, test.u)
+	}
+}
+
+func TestRelativeLinker(t *testing.T) {
+	idx := newTestIndex(t)
+	fallback := func(u rewrite.URL) (string, error) {
+		if u.Value == "http://example.com/rebased" {
+			return "https://example.org/rebased", nil
+		}
+		return "", rewrite.ErrNotModified
+	}
+	page := &relativeLinker{
+		index:      idx,
+		docURL:     mustParseURL(t, "http://example.com/blog/post"),
+		sourcePath: "http-example.com-80/blog/post.html",
+		html:       true,
+		fallback:   fallback,
+	}
+	script := &relativeLinker{
+		index:      idx,
+		docURL:     mustParseURL(t, "http://example.com/static/app.js"),
+		sourcePath: "http-example.com-80/static/app.js",
+		fallback:   fallback,
+	}
+	tests := []struct {
+		name     string
+		rl       *relativeLinker
+		u        rewrite.URL
+		expected string
+	}{
+		{"root", page, rewrite.URL{Value: "/"}, "../index.html"},
+		{"same directory", page, rewrite.URL{Value: "post#comments"}, "post.html#comments"},
+		{"query", page, rewrite.URL{Value: "/page?x=1&a=2"}, "../page%3Fx=1&a=2.html"},
+		{"colon", page, rewrite.URL{Value: "/a:b"}, "../a:b.html"},
+		{"colon in first segment", &relativeLinker{
+			index:      idx,
+			docURL:     mustParseURL(t, "http://example.com/"),
+			sourcePath: "http-example.com-80/index.html",
+		}, rewrite.URL{Value: "/a:b"}, "./a:b.html"},
+		{"space", page, rewrite.URL{Value: "/img/a%20b.png"}, "../img/a%20b.png"},
+		{"redirect", page, rewrite.URL{Value: "/docs"}, "post.html"},
+		{"other site", page, rewrite.URL{Value: "http://other.example.com/"},
+			"../../http-other.example.com-80/index.html"},
+		{"base", page, rewrite.URL{Value: "http://example.com/", Type: rewrite.URLTypeBase}, "./post.html"},
+		{"relative to base", page, rewrite.URL{Value: "static/style.css", Base: "/"}, "../static/style.css"},
+		{"js import", script, rewrite.URL{Value: "/static/style.css", Type: rewrite.URLTypeJSImport},
+			"./style.css"},
+		{"js string in html", page, rewrite.URL{Value: "/static/app.js", Type: rewrite.URLTypeJSString},
+			"../static/app.js"},
+		// Strings in scripts are resolved against the page running the script.
+		{"js string in script", script, rewrite.URL{Value: "/img/a%20b.png", Type: rewrite.URLTypeJSString},
+			"http://example.com/img/a%20b.png"},
+		{"fallback", page, rewrite.URL{Value: "/rebased"}, "https://example.org/rebased"},
+		{"missing relative", page, rewrite.URL{Value: "missing"}, "http://example.com/blog/missing"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newURL, err := test.rl.rewriteURL(test.u)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, newURL)
+		})
+	}
+
+	for _, u := range []rewrite.URL{
+		{Value: "http://example.com/missing"},
+		{Value: "#top"},
+		{Value: ""},
+		{Value: "mailto:a@example.com"},
+		{Value: "http://www.w3.org/2000/svg", Type: rewrite.URLTypeCSSNamespace},
+	} {
+		_, err := page.rewriteURL(u)
+		assert.True(t, errors.Is(err, rewrite.ErrNotModified), u.Value)
+	}
+}
+
+func TestGenerate_RelativeLinks(t *testing.T) {
+	repo, dir := newTestRepository(t)
+	storeDocument(t, repo, "http://example.com/", 200, htmlHeader,
+		`<a href="/blog/">blog</a><link rel="stylesheet" href="/style.css"><a href="/gone">gone</a>`)
+	storeDocument(t, repo, "http://example.com/blog", 301, redirectHeader("http://example.com/blog/"), "")
+	storeDocument(t, repo, "http://example.com/blog/", 200, htmlHeader, `<a href="..">home</a>`)
+	storeDocument(t, repo, "http://example.com/style.css", 200, cssHeader, `a{background:url(/blog)}`)
+	outDir := filepath.Join(dir, "out")
+
+	require.NoError(t, Generate(repo, outDir, Options{RelativeLinks: true}))
+
+	siteDir := filepath.Join(outDir, "http-example.com-80")
+	data, err := ioutil.ReadFile(filepath.Join(siteDir, "index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, `<a href="blog/index.html">blog</a><link rel="stylesheet" href="style.css">`+
+		`<a href="http://example.com/gone">gone</a>`, string(data))
+	data, err = ioutil.ReadFile(filepath.Join(siteDir, "blog", "index.html"))
+	require.NoError(t, err)
+	assert.Equal(t, `<a href="../index.html">home</a>`, string(data))
+	data, err = ioutil.ReadFile(filepath.Join(siteDir, "style.css"))
+	require.NoError(t, err)
+	assert.Equal(t, `a{background:url("blog/index.html")}`, string(data))
+}
@@ -7,5 +7,6 @@ require (
 	github.com/stretchr/testify v1.7.0
 	github.com/tdewolff/parse/v2 v2.5.10
 	github.com/urfave/cli/v2 v2.3.0
+	golang.org/x/text v0.3.6
 	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba
 )
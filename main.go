@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -12,17 +13,23 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"os/signal"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/martin-sucha/site-to-static/rewrite"
+	"github.com/martin-sucha/site-to-static/rules"
 	"github.com/martin-sucha/site-to-static/urlrebase"
+	"github.com/martin-sucha/site-to-static/urlrewrite"
 
+	"github.com/martin-sucha/site-to-static/check"
 	"github.com/martin-sucha/site-to-static/files"
 	"github.com/martin-sucha/site-to-static/httrack"
 	"github.com/martin-sucha/site-to-static/repository"
+	"github.com/martin-sucha/site-to-static/scope"
 	"github.com/martin-sucha/site-to-static/scraper"
 	"github.com/martin-sucha/site-to-static/urlnorm"
 
@@ -40,13 +47,22 @@ func main() {
 			{
 				Name:      "scrape",
 				Usage:     "",
-				ArgsUsage: "repopath url [url...]",
+				ArgsUsage: "repopath [url...]",
 				Action:    doScrape,
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringSliceFlag{
 						Name:  "allow-root",
 						Usage: "URL prefixes to allow",
 					},
+					&cli.StringSliceFlag{
+						Name: "rule",
+						Usage: "Include (+) or exclude (-) URLs matching a pattern, evaluated in order before allow-root. " +
+							"Format is {+|-}{re|glob}:pattern[ type=mediatype,...]",
+					},
+					&cli.StringFlag{
+						Name:  "rules-file",
+						Usage: "File with rules in the same format as --rule, one per line, evaluated before --rule",
+					},
 					&cli.StringFlag{
 						Name:  "user-agent",
 						Usage: "User-Agent string to use",
@@ -59,7 +75,73 @@ func main() {
 						Name:  "strip-https",
 						Usage: "Use plain HTTP (without TLS) for https URLs",
 					},
-				},
+					&cli.StringSliceFlag{
+						Name: "rate",
+						Usage: "Maximum request rate, either for a host as host=rate, or default for other hosts " +
+							"(10/s per host if not set). Rate is number of requests per time unit, e.g. 5/s, 100/m or 1/2s",
+					},
+					&cli.StringFlag{
+						Name:  "total-rate",
+						Usage: "Maximum request rate of all hosts together in the same format as --rate (unlimited if not set)",
+					},
+					&cli.StringSliceFlag{
+						Name: "max-connections",
+						Usage: "Maximum concurrent requests, either for a host as host=count, or default for other hosts " +
+							"(0 or not set means limited only by --workers)",
+					},
+					&cli.IntFlag{
+						Name:  "workers",
+						Usage: "Number of concurrent workers",
+						Value: 10,
+					},
+					&cli.IntFlag{
+						Name:  "retries",
+						Usage: "Number of retries of requests that failed with connection errors, timeouts, 429 or 5xx",
+						Value: 3,
+					},
+					&cli.DurationFlag{
+						Name:  "retry-delay",
+						Usage: "Delay before the first retry, doubled for each subsequent retry",
+						Value: time.Second,
+					},
+					&cli.DurationFlag{
+						Name:  "retry-max-delay",
+						Usage: "Maximum delay between retries",
+						Value: time.Minute,
+					},
+					&cli.BoolFlag{
+						Name:  "resume",
+						Usage: "Resume an interrupted scrape, don't download URLs already stored in the repository",
+					},
+					&cli.BoolFlag{
+						Name:  "incremental",
+						Usage: "Re-download URLs already stored in the repository using conditional requests",
+					},
+					&cli.StringSliceFlag{
+						Name:  "sitemap",
+						Usage: "URL of a sitemap or sitemap index to scrape pages from",
+					},
+					&cli.BoolFlag{
+						Name:  "discover-sitemaps",
+						Usage: "Scrape pages from sitemaps listed in robots.txt, can't be used with --ignore-robots",
+					},
+					&cli.BoolFlag{
+						Name:  "ignore-robots",
+						Usage: "Don't fetch robots.txt, download also URLs disallowed by it",
+					},
+					&cli.IntFlag{
+						Name:  "max-depth",
+						Usage: "Maximum number of links to follow from initial URLs and sitemaps, 0 means no limit",
+					},
+					&cli.Int64Flag{
+						Name:  "max-pages",
+						Usage: "Stop after downloading this many URLs, 0 means no limit",
+					},
+					&cli.Int64Flag{
+						Name:  "max-bytes",
+						Usage: "Stop after downloading this many bytes, 0 means no limit",
+					},
+				}, rewriteOptionFlags()...),
 			},
 			{
 				Name:      "list",
@@ -75,6 +157,10 @@ func main() {
 						Name:  "canonical",
 						Usage: "print canonical URLs",
 					},
+					&cli.BoolFlag{
+						Name:  "failed",
+						Usage: "list urls that could not be fetched instead, with the error (native format only)",
+					},
 				},
 			},
 			{
@@ -125,17 +211,62 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:      "links",
+				Usage:     "show links to and from urls stored in a repository",
+				ArgsUsage: "repopath url...",
+				Action:    doLinks,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "inbound",
+						Usage: "show only links pointing to url",
+					},
+					&cli.BoolFlag{
+						Name:  "outbound",
+						Usage: "show only links from url",
+					},
+				},
+			},
+			{
+				Name:      "check",
+				Usage:     "report broken links in documents stored in a repository",
+				ArgsUsage: "repopath",
+				Action:    doCheck,
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "either text or json",
+					},
+					&cli.BoolFlag{
+						Name:  "ignore-redirects",
+						Usage: "don't report links to redirects with a working target",
+					},
+				}, rewriteOptionFlags()...),
+			},
 			{
 				Name:      "files",
 				Usage:     "copy files to directory",
 				ArgsUsage: "repopath outdir",
 				Action:    doFiles,
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringSliceFlag{
 						Name:  "rewrite-url",
 						Usage: "oldURL|newURL",
 					},
-				},
+					&cli.StringSliceFlag{
+						Name: "rewrite-rule",
+						Usage: "Rewrite URLs matching a pattern, rules are applied in order before rewrite-url. " +
+							"Format is {re|glob|host|scheme}:pattern replacement",
+					},
+					&cli.StringFlag{
+						Name:  "rewrite-rules-file",
+						Usage: "File with rewrite rules in the same format as --rewrite-rule, one per line, applied first",
+					},
+					&cli.BoolFlag{
+						Name:  "relative-links",
+						Usage: "rewrite links to stored documents to relative paths of the generated files",
+					},
+				}, rewriteOptionFlags()...),
 			},
 		},
 	}
@@ -147,7 +278,7 @@ func main() {
 }
 
 func doScrape(c *cli.Context) error {
-	if c.Args().Len() < 2 {
+	if c.Args().Len() < 1 || (c.Args().Len() < 2 && len(c.StringSlice("sitemap")) == 0) {
 		return fmt.Errorf("not enough arguments")
 	}
 	repoPath := c.Args().First()
@@ -161,6 +292,16 @@ func doScrape(c *cli.Context) error {
 		initialURLs = append(initialURLs, u)
 	}
 
+	sitemapArgs := c.StringSlice("sitemap")
+	sitemapURLs := make([]*url.URL, 0, len(sitemapArgs))
+	for _, arg := range sitemapArgs {
+		u, err := url.Parse(arg)
+		if err != nil {
+			return fmt.Errorf("parse sitemap url %q: %v", arg, err)
+		}
+		sitemapURLs = append(sitemapURLs, u)
+	}
+
 	rootStrings := c.StringSlice("allow-root")
 	rootKeys := make([]string, 0, len(rootStrings))
 	for _, arg := range rootStrings {
@@ -171,6 +312,23 @@ func doScrape(c *cli.Context) error {
 		rootKeys = append(rootKeys, repository.Key(u))
 	}
 
+	matcher, err := loadScopeRules(c.String("rules-file"), c.StringSlice("rule"))
+	if err != nil {
+		return err
+	}
+	rewriteOptions, err := loadRewriteOptions(c)
+	if err != nil {
+		return err
+	}
+	allowRoot := func(key string) bool {
+		for _, root := range rootKeys {
+			if strings.HasPrefix(key, root) {
+				return true
+			}
+		}
+		return false
+	}
+
 	var httpClient http.Client
 	dialer := &net.Dialer{
 		Timeout:   30 * time.Second,
@@ -210,26 +368,274 @@ func doScrape(c *cli.Context) error {
 		httpClient.Transport = &stripHTTPSRoundTripper{rt: httpClient.Transport}
 	}
 
+	defaultHostLimit, hostLimits, err := parseHostLimits(c.StringSlice("rate"), c.StringSlice("max-connections"))
+	if err != nil {
+		return err
+	}
+	var totalLimiter *rate.Limiter
+	if totalRate := c.String("total-rate"); totalRate != "" {
+		limit, err := parseRate(totalRate)
+		if err != nil {
+			return fmt.Errorf("parse total-rate %q: %v", totalRate, err)
+		}
+		totalLimiter = rate.NewLimiter(limit, 1)
+	}
+	workers := c.Int("workers")
+	if workers < 1 {
+		return fmt.Errorf("workers must be at least 1")
+	}
+	if c.Int("max-depth") < 0 || c.Int64("max-pages") < 0 || c.Int64("max-bytes") < 0 {
+		return fmt.Errorf("max-depth, max-pages and max-bytes must not be negative")
+	}
+
 	repo := repository.New(repoPath)
 	sc := scraper.Scraper{
-		Client:     httpClient,
-		Repository: repo,
-		Limiter:    rate.NewLimiter(10, 1),
+		Client:           httpClient,
+		Repository:       repo,
+		Limiter:          totalLimiter,
+		DefaultHostLimit: defaultHostLimit,
+		HostLimits:       hostLimits,
+		Retry: scraper.RetryPolicy{
+			MaxAttempts:  c.Int("retries") + 1,
+			InitialDelay: c.Duration("retry-delay"),
+			MaxDelay:     c.Duration("retry-max-delay"),
+		},
 		FollowURL: func(u *url.URL) bool {
 			key := repository.Key(u)
-			for _, root := range rootKeys {
-				if strings.HasPrefix(key, root) {
-					return true
-				}
+			if include, ok := matcher.Match(key, ""); ok {
+				return include
+			}
+			return allowRoot(key)
+		},
+		FollowResponse: func(u *url.URL, mediatype string) bool {
+			key := repository.Key(u)
+			if include, ok := matcher.Match(key, mediatype); ok {
+				return include
 			}
-			return false
+			return allowRoot(key)
+		},
+		UserAgent:        c.String("user-agent"),
+		Resume:           c.Bool("resume"),
+		Incremental:      c.Bool("incremental"),
+		Sitemaps:         sitemapURLs,
+		DiscoverSitemaps: c.Bool("discover-sitemaps"),
+		IgnoreRobots:     c.Bool("ignore-robots"),
+		MaxDepth:         c.Int("max-depth"),
+		MaxPages:         c.Int64("max-pages"),
+		MaxBytes:         c.Int64("max-bytes"),
+		RewriteOptions:   rewriteOptions,
+	}
+
+	ctx, cancel := context.WithCancel(c.Context)
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+		// Restore default signal handling so that another signal terminates the process immediately.
+		signal.Stop(signals)
+	}()
+	return sc.Scrape(ctx, initialURLs, workers)
+}
+
+// rewriteOptionFlags returns flags configuring discovery and rewriting of URLs in documents.
+func rewriteOptionFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "lazy-load",
+			Usage: "Handle attributes commonly used by lazy-loading scripts, like data-src, data-srcset and data-bg",
+		},
+		&cli.StringSliceFlag{
+			Name: "attribute",
+			Usage: "Additional HTML attribute containing URLs. Format is \"tag attribute kind\", tag may be * " +
+				"and kind is one of url, url-list, srcset, css and background",
+		},
+		&cli.StringFlag{
+			Name:  "attributes-file",
+			Usage: "File with attributes in the same format as --attribute, one per line",
+		},
+		&cli.StringSliceFlag{
+			Name: "json-key",
+			Usage: "Additional key of properties with URL values in JSON-LD and web app manifests " +
+				"(like @id, url, image, start_url and src)",
 		},
-		UserAgent: c.String("user-agent"),
 	}
-	sc.Scrape(initialURLs, 10)
+}
+
+// loadRewriteOptions returns rewrite options configured by rewriteOptionFlags.
+// Attributes from the attributes file override lazy-load ones and are overridden by --attribute.
+func loadRewriteOptions(c *cli.Context) (*rewrite.Options, error) {
+	opts := &rewrite.Options{}
+	if c.Bool("lazy-load") {
+		opts.Attributes = append(opts.Attributes, rewrite.LazyLoadAttributes...)
+	}
+	err := loadRules(c.String("attributes-file"), c.StringSlice("attribute"), func(s string) error {
+		attr, err := rewrite.ParseAttribute(s)
+		if err != nil {
+			return err
+		}
+		opts.Attributes = append(opts.Attributes, attr)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if jsonKeys := c.StringSlice("json-key"); len(jsonKeys) > 0 {
+		opts.JSONKeys = append(append([]string(nil), rewrite.DefaultJSONKeys...), jsonKeys...)
+	}
+	return opts, nil
+}
+
+// loadRules calls addRule for each line of rulesFile (if not empty) and then for each of ruleArgs.
+func loadRules(rulesFile string, ruleArgs []string, addRule func(s string) error) error {
+	if rulesFile != "" {
+		f, err := os.Open(rulesFile)
+		if err != nil {
+			return err
+		}
+		err = rules.ParseLines(f, addRule)
+		closeErr := f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", rulesFile, err)
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+	for _, s := range ruleArgs {
+		err := addRule(s)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// loadScopeRules loads rules from rulesFile (if not empty) followed by ruleArgs.
+func loadScopeRules(rulesFile string, ruleArgs []string) (*scope.Matcher, error) {
+	matcher := &scope.Matcher{}
+	err := loadRules(rulesFile, ruleArgs, func(s string) error {
+		rule, err := scope.ParseRule(s)
+		if err != nil {
+			return err
+		}
+		matcher.Rules = append(matcher.Rules, rule)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matcher, nil
+}
+
+// loadURLRewriteRules loads URL rewrite rules from rulesFile (if not empty) followed by ruleArgs.
+func loadURLRewriteRules(rulesFile string, ruleArgs []string) (*urlrewrite.Rewriter, error) {
+	rewriter := &urlrewrite.Rewriter{}
+	err := loadRules(rulesFile, ruleArgs, func(s string) error {
+		rule, err := urlrewrite.ParseRule(s)
+		if err != nil {
+			return err
+		}
+		rewriter.Rules = append(rewriter.Rules, rule)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rewriter, nil
+}
+
+func parseHostLimits(rates, maxConnections []string) (scraper.HostLimit, map[string]scraper.HostLimit, error) {
+	defaultLimit := scraper.HostLimit{
+		Rate: 10,
+	}
+	hostLimits := make(map[string]scraper.HostLimit)
+	for _, arg := range rates {
+		host, value := splitHostValue(arg)
+		limit, err := parseRate(value)
+		if err != nil {
+			return defaultLimit, nil, fmt.Errorf("parse rate %q: %v", arg, err)
+		}
+		if host == "" {
+			defaultLimit.Rate = limit
+			continue
+		}
+		hostLimit := hostLimits[host]
+		hostLimit.Rate = limit
+		hostLimits[host] = hostLimit
+	}
+	for _, arg := range maxConnections {
+		host, value := splitHostValue(arg)
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return defaultLimit, nil, fmt.Errorf("parse max-connections %q: %v", arg, err)
+		}
+		if count < 0 {
+			return defaultLimit, nil, fmt.Errorf("parse max-connections %q: count must not be negative", arg)
+		}
+		if host == "" {
+			defaultLimit.MaxConcurrent = count
+			continue
+		}
+		hostLimit := hostLimits[host]
+		hostLimit.MaxConcurrent = count
+		hostLimits[host] = hostLimit
+	}
+	// Hosts with only one of the limits specified use default for the other one.
+	for host, hostLimit := range hostLimits {
+		if hostLimit.Rate == 0 {
+			hostLimit.Rate = defaultLimit.Rate
+		}
+		if hostLimit.MaxConcurrent == 0 {
+			hostLimit.MaxConcurrent = defaultLimit.MaxConcurrent
+		}
+		hostLimits[host] = hostLimit
+	}
+	return defaultLimit, hostLimits, nil
+}
+
+// splitHostValue splits host=value. host is empty if there is no =.
+func splitHostValue(s string) (host, value string) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return "", s
+	}
+	u := urlnorm.Canonical(&url.URL{Host: parts[0]})
+	return u.Host, parts[1]
+}
+
+// parseRate parses rate in format count/unit, for example 5/s, 100/m or 1/2s.
+func parseRate(s string) (rate.Limit, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("expected count/unit")
+	}
+	count, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	if count <= 0 {
+		return 0, fmt.Errorf("count must be positive")
+	}
+	unit := parts[1]
+	if unit != "" && (unit[0] < '0' || unit[0] > '9') {
+		unit = "1" + unit
+	}
+	unitDuration, err := time.ParseDuration(unit)
+	if err != nil {
+		return 0, err
+	}
+	if unitDuration <= 0 {
+		return 0, fmt.Errorf("unit must be positive")
+	}
+	return rate.Limit(count / unitDuration.Seconds()), nil
+}
+
 type stripHTTPSRoundTripper struct {
 	rt http.RoundTripper
 }
@@ -274,6 +680,12 @@ func doList(c *cli.Context) error {
 	}
 
 	repoPath := c.Args().First()
+	if c.Bool("failed") {
+		if format != "" && format != "native" {
+			return fmt.Errorf("failed is only supported with native format")
+		}
+		return listFailures(repository.New(repoPath))
+	}
 	switch format {
 	case "", "native":
 		repo := repository.New(repoPath)
@@ -311,6 +723,31 @@ func doList(c *cli.Context) error {
 	return nil
 }
 
+// listFailures prints urls that could not be fetched, sorted by url.
+func listFailures(repo *repository.Repository) error {
+	failures, err := repo.ListFailures()
+	if err != nil {
+		return err
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].URL < failures[j].URL
+	})
+	for _, failure := range failures {
+		_, err = fmt.Printf("%s\t%s\t%d attempts\t%s\n", failure.URL, failure.Time.Format(time.RFC3339),
+			failure.Attempts, failure.Error)
+		if err != nil {
+			return err
+		}
+		if failure.Referrer != "" {
+			_, err = fmt.Printf("\tlinked from %s\n", failure.Referrer)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 type entryData struct {
 	Response *http.Response
 	Body     []byte
@@ -689,6 +1126,120 @@ func doShowFile(c *cli.Context) error {
 	return showDoc(doc)
 }
 
+func doLinks(c *cli.Context) error {
+	if c.Args().Len() < 2 {
+		return fmt.Errorf("not enough arguments")
+	}
+	repo := repository.New(c.Args().First())
+	var keys []string
+	for _, arg := range c.Args().Slice()[1:] {
+		parsedURL, err := url.Parse(arg)
+		if err != nil {
+			return err
+		}
+		if !parsedURL.IsAbs() {
+			return fmt.Errorf("must be absolute url: %s", arg)
+		}
+		keys = append(keys, repository.Key(parsedURL))
+	}
+	showInbound := c.Bool("inbound") || !c.Bool("outbound")
+	showOutbound := c.Bool("outbound") || !c.Bool("inbound")
+
+	var inboundIndex *repository.InboundIndex
+	if showInbound {
+		var err error
+		inboundIndex, err = repo.NewInboundIndex()
+		if err != nil {
+			return err
+		}
+	}
+	for i, key := range keys {
+		if len(keys) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s:\n", key)
+		}
+		if showOutbound {
+			links, err := repo.LoadLinks(key)
+			if err != nil {
+				return err
+			}
+			for _, link := range links.Links {
+				fmt.Printf("to %s\t%s\n", link.TargetURL, describeLink(link))
+			}
+		}
+		if showInbound {
+			inbound := inboundIndex.Inbound(key)
+			sort.Slice(inbound, func(i, j int) bool {
+				return inbound[i].SourceURL < inbound[j].SourceURL
+			})
+			for _, links := range inbound {
+				for _, link := range links.Links {
+					fmt.Printf("from %s\t%s\n", links.SourceURL, describeLink(link))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// describeLink returns type of the link and where it was found in the document.
+func describeLink(link repository.Link) string {
+	switch {
+	case link.Tag != "" && link.Attribute != "":
+		return fmt.Sprintf("%s <%s %s>", link.Type, link.Tag, link.Attribute)
+	case link.Tag != "":
+		return fmt.Sprintf("%s <%s>", link.Type, link.Tag)
+	default:
+		return link.Type
+	}
+}
+
+func doCheck(c *cli.Context) error {
+	if c.Args().Len() < 1 {
+		return fmt.Errorf("not enough arguments")
+	}
+	format := c.String("format")
+	if format != "" && format != "text" && format != "json" {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+	rewriteOptions, err := loadRewriteOptions(c)
+	if err != nil {
+		return err
+	}
+	repo := repository.New(c.Args().First())
+	report, err := check.Run(repo, check.Options{
+		IgnoreRedirects: c.Bool("ignore-redirects"),
+		Rewrite:         rewriteOptions,
+	})
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	for _, page := range report.Pages {
+		fmt.Println(page.URL)
+		for _, problem := range page.Problems {
+			fmt.Printf("\t%s %s", problem.Kind, problem.URL)
+			for _, redirect := range problem.Redirects {
+				fmt.Printf(" -> %s", redirect)
+			}
+			if problem.StatusCode != 0 {
+				fmt.Printf(" (%d)", problem.StatusCode)
+			}
+			if problem.Error != "" {
+				fmt.Printf(": %s", problem.Error)
+			}
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
 func doFiles(c *cli.Context) error {
 	if c.Args().Len() < 2 {
 		return fmt.Errorf("not enough arguments")
@@ -701,30 +1252,64 @@ func doFiles(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	rewriteOptions, err := loadRewriteOptions(c)
+	if err != nil {
+		return err
+	}
 
-	var urlRewriter rewrite.URLRewriter
-	if len(mappings) > 0 {
-		urlRewriter = func(urlInfo rewrite.URL) (string, error) {
-			parsedURL, err := url.Parse(strings.TrimSpace(urlInfo.Value))
-			if err != nil {
-				return "", err
-			}
-			for _, mapping := range mappings {
-				newURL, err := urlrebase.Rebase(parsedURL, mapping.oldURL, mapping.newURL)
+	rewriteRules, err := loadURLRewriteRules(c.String("rewrite-rules-file"), c.StringSlice("rewrite-rule"))
+	if err != nil {
+		return err
+	}
+
+	var urlRewriter func(docURL *url.URL) rewrite.URLRewriter
+	if len(mappings) > 0 || len(rewriteRules.Rules) > 0 {
+		urlRewriter = func(docURL *url.URL) rewrite.URLRewriter {
+			return func(urlInfo rewrite.URL) (string, error) {
+				if !urlInfo.IsFetchable() {
+					return "", rewrite.ErrNotModified
+				}
+				parsedURL, err := url.Parse(strings.TrimSpace(urlInfo.Value))
+				if err != nil {
+					return "", err
+				}
+				docBase := docURL
+				if urlInfo.Base != "" {
+					parsedBase, err := url.Parse(strings.TrimSpace(urlInfo.Base))
+					if err != nil {
+						return "", err
+					}
+					docBase = docURL.ResolveReference(parsedBase)
+				}
+				newURL, err := rewriteRules.Rewrite(parsedURL, docBase)
 				switch {
-				case errors.Is(err, urlrebase.ErrNoBase):
-					continue
+				case errors.Is(err, urlrewrite.ErrNoMatch):
 				case err != nil:
 					return "", err
 				default:
 					return newURL.String(), nil
 				}
+				for _, mapping := range mappings {
+					newURL, err := urlrebase.Rebase(parsedURL, docBase, mapping.oldURL, mapping.newURL)
+					switch {
+					case errors.Is(err, urlrebase.ErrNoBase):
+						continue
+					case err != nil:
+						return "", err
+					default:
+						return newURL.String(), nil
+					}
+				}
+				return "", rewrite.ErrNotModified
 			}
-			return "", rewrite.ErrNotModified
 		}
 	}
 
-	return files.Generate(repo, outputPath, urlRewriter)
+	return files.Generate(repo, outputPath, files.Options{
+		URLRewriter:   urlRewriter,
+		Rewrite:       rewriteOptions,
+		RelativeLinks: c.Bool("relative-links"),
+	})
 }
 
 func parseURLMapping(c *cli.Context) ([]urlMapping, error) {
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"encoding/base32"
+	"time"
+)
+
+// Failure records an attempt to fetch a URL that did not result in any complete HTTP response,
+// for example because of a DNS or TLS error or because the connection was reset while reading the body.
+//
+// Failures are stored next to documents, in files with .failed extension containing JSON.
+// A failure record is removed when a document with the same key is stored.
+type Failure struct {
+	Key string
+	URL string
+	// Referrer is the URL of the document that linked to URL, if known.
+	Referrer string `json:",omitempty"`
+	// Error is the error message of the last attempt.
+	Error string
+	// Time is the time when the last attempt failed.
+	Time time.Time
+	// Attempts is the number of attempts made.
+	Attempts int
+}
+
+const failureSuffix = ".failed"
+
+// StoreFailure stores the failure record, replacing any previous failure record with the same key.
+func (r *Repository) StoreFailure(failure *Failure) error {
+	return r.writeJSONFile(keyToFailureFilename(failure.Key), failure)
+}
+
+// ListFailures returns all failure records stored in the repository.
+func (r *Repository) ListFailures() ([]*Failure, error) {
+	names, err := r.listFiles(failureSuffix)
+	if err != nil {
+		return nil, err
+	}
+	failures := make([]*Failure, 0, len(names))
+	for _, name := range names {
+		failure := &Failure{}
+		err = r.readJSONFile(name, failure)
+		if err != nil {
+			return nil, err
+		}
+		failures = append(failures, failure)
+	}
+	return failures, nil
+}
+
+// removeFailure removes failure record for the key if it exists.
+func (r *Repository) removeFailure(key string) error {
+	return r.removeFile(keyToFailureFilename(key))
+}
+
+func keyToFailureFilename(key string) string {
+	return base32.StdEncoding.EncodeToString([]byte(key)) + failureSuffix
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repository-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	r := New(dir)
+
+	failure := &Failure{
+		Key:      "http://example.com/a",
+		URL:      "http://example.com/a",
+		Error:    "dial tcp: connection refused",
+		Time:     time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		Attempts: 3,
+	}
+	require.NoError(t, r.StoreFailure(failure))
+
+	failures, err := r.ListFailures()
+	require.NoError(t, err)
+	require.Equal(t, []*Failure{failure}, failures)
+
+	entries, err := r.List()
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	dw, err := r.NewWriter()
+	require.NoError(t, err)
+	_, err = dw.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, dw.Close(&DocumentMetadata{
+		Key:        failure.Key,
+		URL:        failure.URL,
+		StatusCode: 200,
+	}))
+
+	failures, err = r.ListFailures()
+	require.NoError(t, err)
+	require.Empty(t, failures)
+
+	doc, err := r.Load(failure.Key)
+	require.NoError(t, err)
+	defer doc.Close()
+	body, err := ioutil.ReadAll(doc.Body())
+	require.NoError(t, err)
+	require.True(t, bytes.Equal([]byte("hello"), body))
+}
@@ -0,0 +1,44 @@
+package repository
+
+import "time"
+
+const frontierFilename = "frontier.json"
+
+// Frontier is a snapshot of the state of a scrape.
+// It is stored in the repository so that an interrupted scrape can be resumed.
+type Frontier struct {
+	// StartedTime is the time when the scrape was started.
+	StartedTime time.Time
+	// Pending contains tasks that were discovered, but not completed yet.
+	Pending []FrontierTask
+	// Seen contains keys of all discovered tasks, including the pending ones.
+	Seen []string
+}
+
+// FrontierTask is a single task pending in the Frontier.
+type FrontierTask struct {
+	URL string
+	Key string
+	// Sitemap is true if the URL is a sitemap.
+	Sitemap bool `json:",omitempty"`
+	// Depth is the number of links followed from an initial URL or sitemap.
+	Depth int `json:",omitempty"`
+	// Referrer is the URL of the document that linked to URL.
+	Referrer string `json:",omitempty"`
+}
+
+// SaveFrontier atomically replaces the frontier stored in the repository.
+func (r *Repository) SaveFrontier(frontier *Frontier) error {
+	return r.writeJSONFile(frontierFilename, frontier)
+}
+
+// LoadFrontier loads the frontier stored by SaveFrontier.
+// If no frontier was stored, the returned error satisfies errors.Is(err, os.ErrNotExist).
+func (r *Repository) LoadFrontier() (*Frontier, error) {
+	var frontier Frontier
+	err := r.readJSONFile(frontierFilename, &frontier)
+	if err != nil {
+		return nil, err
+	}
+	return &frontier, nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// writeJSONFile atomically replaces file in the repository with v encoded as JSON.
+func (r *Repository) writeJSONFile(filename string, v interface{}) (outErr error) {
+	f, err := ioutil.TempFile(r.path, "tmp-")
+	if err != nil {
+		return err
+	}
+	closed := false
+	defer func() {
+		if outErr != nil {
+			// TODO: log errors
+			if !closed {
+				_ = f.Close()
+			}
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	err = json.NewEncoder(f).Encode(v)
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	closed = true
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path.Join(r.path, filename))
+}
+
+// readJSONFile decodes JSON stored in file in the repository to v.
+// If the file does not exist, the returned error satisfies errors.Is(err, os.ErrNotExist).
+func (r *Repository) readJSONFile(filename string, v interface{}) error {
+	filePath := path.Join(r.path, filename)
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf("%s: %v", filePath, err)
+	}
+	return nil
+}
+
+// listFiles returns names of files in the repository with the suffix.
+func (r *Repository) listFiles(suffix string) ([]string, error) {
+	f, err := os.Open(r.path)
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(-1)
+	closeErr := f.Close()
+	if err != nil {
+		return nil, err
+	}
+	if closeErr != nil {
+		return nil, closeErr
+	}
+	filtered := names[:0]
+	for _, name := range names {
+		if strings.HasPrefix(name, "tmp-") || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		filtered = append(filtered, name)
+	}
+	return filtered, nil
+}
+
+// removeFile removes file from the repository if it exists.
+func (r *Repository) removeFile(filename string) error {
+	err := os.Remove(path.Join(r.path, filename))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
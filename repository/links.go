@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"encoding/base32"
+	"errors"
+	"os"
+)
+
+// Links records links found in a single document.
+//
+// Links are stored next to documents, in files with .links extension containing JSON.
+// Storing links of a document replaces links stored for it previously.
+type Links struct {
+	// SourceKey is the key of the document containing the links.
+	SourceKey string
+	// SourceURL is the URL of the document containing the links.
+	SourceURL string
+	Links     []Link
+}
+
+// Link is a single link from a document.
+type Link struct {
+	// TargetKey is the key of the linked URL.
+	TargetKey string
+	// TargetURL is the absolute linked URL.
+	TargetURL string
+	// Type is the type of the URL as returned by rewrite.URLType.String, or LinkTypeSitemap.
+	Type string
+	// Tag is the HTML element containing the link, if any.
+	Tag string `json:",omitempty"`
+	// Attribute is the HTML attribute containing the link, if any.
+	Attribute string `json:",omitempty"`
+}
+
+// LinkTypeSitemap is the Type of links to pages and sitemaps listed in sitemaps and robots.txt.
+const LinkTypeSitemap = "sitemap"
+
+const linksSuffix = ".links"
+
+// StoreLinks stores links of a document, replacing links stored for the same SourceKey.
+func (r *Repository) StoreLinks(links *Links) error {
+	return r.writeJSONFile(keyToLinksFilename(links.SourceKey), links)
+}
+
+// LoadLinks loads links of the document with the key.
+// If no links were stored for the key, empty Links are returned.
+func (r *Repository) LoadLinks(key string) (*Links, error) {
+	links := &Links{}
+	err := r.readJSONFile(keyToLinksFilename(key), links)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Links{SourceKey: key}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// ListLinks returns links of all documents stored in the repository.
+func (r *Repository) ListLinks() ([]*Links, error) {
+	names, err := r.listFiles(linksSuffix)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Links, 0, len(names))
+	for _, name := range names {
+		links := &Links{}
+		err = r.readJSONFile(name, links)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, links)
+	}
+	return out, nil
+}
+
+// InboundIndex is a reverse index of links stored in a repository.
+type InboundIndex struct {
+	inbound map[string][]*Links
+}
+
+// NewInboundIndex reads links of all documents stored in the repository and indexes them by target key.
+// The index is not updated when links are stored later.
+func (r *Repository) NewInboundIndex() (*InboundIndex, error) {
+	all, err := r.ListLinks()
+	if err != nil {
+		return nil, err
+	}
+	idx := &InboundIndex{inbound: make(map[string][]*Links)}
+	for _, links := range all {
+		byTarget := make(map[string]*Links)
+		for _, link := range links.Links {
+			inbound, ok := byTarget[link.TargetKey]
+			if !ok {
+				inbound = &Links{
+					SourceKey: links.SourceKey,
+					SourceURL: links.SourceURL,
+				}
+				byTarget[link.TargetKey] = inbound
+				idx.inbound[link.TargetKey] = append(idx.inbound[link.TargetKey], inbound)
+			}
+			inbound.Links = append(inbound.Links, link)
+		}
+	}
+	return idx, nil
+}
+
+// Inbound returns links pointing to the key, grouped by the document containing them.
+func (idx *InboundIndex) Inbound(key string) []*Links {
+	return idx.inbound[key]
+}
+
+func keyToLinksFilename(key string) string {
+	return base32.StdEncoding.EncodeToString([]byte(key)) + linksSuffix
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repository-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	r := New(dir)
+
+	linksA := &Links{
+		SourceKey: "http://example.com/a",
+		SourceURL: "http://example.com/a",
+		Links: []Link{
+			{TargetKey: "http://example.com/b", TargetURL: "http://example.com/b", Type: "unknown", Tag: "a",
+				Attribute: "href"},
+			{TargetKey: "http://example.com/c", TargetURL: "http://example.com/c", Type: "css", Tag: "style"},
+		},
+	}
+	linksB := &Links{
+		SourceKey: "http://example.com/b",
+		SourceURL: "http://example.com/b",
+		Links: []Link{
+			{TargetKey: "http://example.com/c", TargetURL: "http://example.com/c#x", Type: "unknown", Tag: "a",
+				Attribute: "href"},
+		},
+	}
+	require.NoError(t, r.StoreLinks(linksA))
+	require.NoError(t, r.StoreLinks(linksB))
+
+	loaded, err := r.LoadLinks("http://example.com/a")
+	require.NoError(t, err)
+	require.Equal(t, linksA, loaded)
+
+	loaded, err = r.LoadLinks("http://example.com/missing")
+	require.NoError(t, err)
+	require.Equal(t, &Links{SourceKey: "http://example.com/missing"}, loaded)
+
+	idx, err := r.NewInboundIndex()
+	require.NoError(t, err)
+	require.Equal(t, []*Links{{
+		SourceKey: "http://example.com/a",
+		SourceURL: "http://example.com/a",
+		Links:     linksA.Links[:1],
+	}}, idx.Inbound("http://example.com/b"))
+	require.Len(t, idx.Inbound("http://example.com/c"), 2)
+	require.Empty(t, idx.Inbound("http://example.com/a"))
+
+	// Storing links again replaces them.
+	linksA.Links = linksA.Links[:1]
+	require.NoError(t, r.StoreLinks(linksA))
+	idx, err = r.NewInboundIndex()
+	require.NoError(t, err)
+	require.Equal(t, []*Links{linksB}, idx.Inbound("http://example.com/c"))
+}
+
+func TestInboundIndex_MultipleLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repository-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	r := New(dir)
+
+	links := &Links{
+		SourceKey: "http://example.com/sitemap.xml",
+		SourceURL: "http://example.com/sitemap.xml",
+		Links: []Link{
+			{TargetKey: "http://example.com/a", TargetURL: "http://example.com/a", Type: LinkTypeSitemap},
+			{TargetKey: "http://example.com/b", TargetURL: "http://example.com/b", Type: LinkTypeSitemap},
+			{TargetKey: "http://example.com/a", TargetURL: "http://example.com/a#x", Type: LinkTypeSitemap},
+		},
+	}
+	require.NoError(t, r.StoreLinks(links))
+	idx, err := r.NewInboundIndex()
+	require.NoError(t, err)
+	// Links from the same document are grouped together.
+	require.Equal(t, []*Links{{
+		SourceKey: links.SourceKey,
+		SourceURL: links.SourceURL,
+		Links:     []Link{links.Links[0], links.Links[2]},
+	}}, idx.Inbound("http://example.com/a"))
+}
@@ -114,6 +114,23 @@ func (d *DocumentWriter) Write(b []byte) (n int, err error) {
 	return
 }
 
+// BodySHA256 returns SHA-256 digest of the body written so far.
+func (d *DocumentWriter) BodySHA256() [sha256.Size]byte {
+	var sum [sha256.Size]byte
+	d.bodyHasher.Sum(sum[:0])
+	return sum
+}
+
+// Discard removes the partially written document without storing it.
+func (d *DocumentWriter) Discard() error {
+	closeErr := d.f.Close()
+	err := os.Remove(d.f.Name())
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
 func (d *DocumentWriter) Close(metadata *DocumentMetadata) error {
 	closed := false
 	defer func() {
@@ -159,7 +176,39 @@ func (d *DocumentWriter) Close(metadata *DocumentMetadata) error {
 		return err
 	}
 	filename := keyToFilename(metadata.Key)
-	return os.Rename(d.f.Name(), path.Join(d.r.path, filename))
+	err = os.Rename(d.f.Name(), path.Join(d.r.path, filename))
+	if err != nil {
+		return err
+	}
+	return d.r.removeFailure(metadata.Key)
+}
+
+// UpdateMetadata stores the document with the key again with metadata modified by update, keeping its body.
+func (r *Repository) UpdateMetadata(key string, update func(metadata *DocumentMetadata)) error {
+	doc, err := r.Load(key)
+	if err != nil {
+		return err
+	}
+	dw, err := r.NewWriter()
+	if err != nil {
+		_ = doc.Close()
+		return err
+	}
+	_, err = io.Copy(dw, doc.Body())
+	closeErr := doc.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		discardErr := dw.Discard()
+		if discardErr != nil {
+			return fmt.Errorf("%v (discard: %v)", err, discardErr)
+		}
+		return err
+	}
+	metadata := doc.Metadata
+	update(&metadata)
+	return dw.Close(&metadata)
 }
 
 func (r *Repository) Load(key string) (outDoc *Document, outErr error) {
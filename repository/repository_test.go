@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentWriter_BodySHA256(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repository-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	r := New(dir)
+
+	dw, err := r.NewWriter()
+	require.NoError(t, err)
+	assert.Equal(t, sha256.Sum256(nil), dw.BodySHA256())
+	_, err = dw.Write([]byte("hello "))
+	require.NoError(t, err)
+	assert.Equal(t, sha256.Sum256([]byte("hello ")), dw.BodySHA256())
+	_, err = dw.Write([]byte("world"))
+	require.NoError(t, err)
+	sum := dw.BodySHA256()
+	assert.Equal(t, sha256.Sum256([]byte("hello world")), sum)
+	require.NoError(t, dw.Close(&DocumentMetadata{Key: "http://example.com/", URL: "http://example.com/"}))
+
+	doc, err := r.Load("http://example.com/")
+	require.NoError(t, err)
+	defer doc.Close()
+	assert.Equal(t, sum, doc.BodySHA256)
+}
+
+func TestRepository_UpdateMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repository-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	r := New(dir)
+
+	dw, err := r.NewWriter()
+	require.NoError(t, err)
+	_, err = dw.Write([]byte("hello world"))
+	require.NoError(t, err)
+	meta := &DocumentMetadata{
+		Key:                 "http://example.com/",
+		URL:                 "http://example.com/",
+		DownloadStartedTime: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		StatusCode:          200,
+	}
+	require.NoError(t, dw.Close(meta))
+	newTime := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, r.UpdateMetadata("http://example.com/", func(metadata *DocumentMetadata) {
+		metadata.DownloadStartedTime = newTime
+	}))
+
+	doc, err := r.Load("http://example.com/")
+	require.NoError(t, err)
+	defer doc.Close()
+	assert.True(t, doc.Metadata.DownloadStartedTime.Equal(newTime))
+	assert.Equal(t, 200, doc.Metadata.StatusCode)
+	assert.Equal(t, sha256.Sum256([]byte("hello world")), doc.BodySHA256)
+	data, err := ioutil.ReadAll(doc.Body())
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+}
@@ -0,0 +1,127 @@
+package rewrite
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/martin-sucha/site-to-static/rules"
+)
+
+// AttributeKind is the kind of value of an HTML attribute containing URLs.
+type AttributeKind uint8
+
+const (
+	// AttributeURL is an attribute with a single URL, like src.
+	AttributeURL AttributeKind = iota
+	// AttributeURLList is an attribute with space separated URLs, like archive of object.
+	AttributeURLList
+	// AttributeSrcSet is an attribute with image candidate strings, like srcset.
+	AttributeSrcSet
+	// AttributeCSS is an attribute with CSS declarations or values, like style.
+	AttributeCSS
+	// AttributeBackground is an attribute with CSS values like AttributeCSS, or a single URL if it contains no url().
+	// Lazy-loading scripts use both forms for background images.
+	AttributeBackground
+)
+
+var attributeKindNames = [...]string{
+	AttributeURL:        "url",
+	AttributeURLList:    "url-list",
+	AttributeSrcSet:     "srcset",
+	AttributeCSS:        "css",
+	AttributeBackground: "background",
+}
+
+func (k AttributeKind) String() string {
+	if int(k) < len(attributeKindNames) {
+		return attributeKindNames[k]
+	}
+	return fmt.Sprintf("AttributeKind(%d)", k)
+}
+
+// Attribute describes an HTML attribute containing URLs in addition to the built-in ones.
+type Attribute struct {
+	// Tag is the lowercase name of the element, * matches all elements.
+	Tag string
+	// Name is the lowercase name of the attribute.
+	Name string
+	// Kind of the attribute value.
+	Kind AttributeKind
+}
+
+// LazyLoadAttributes are attributes commonly used by lazy-loading scripts to hold the real image URLs.
+var LazyLoadAttributes = []Attribute{
+	{Tag: "*", Name: "data-src", Kind: AttributeURL},
+	{Tag: "*", Name: "data-lazy-src", Kind: AttributeURL},
+	{Tag: "*", Name: "data-srcset", Kind: AttributeSrcSet},
+	{Tag: "*", Name: "data-lazy-srcset", Kind: AttributeSrcSet},
+	{Tag: "*", Name: "data-bg", Kind: AttributeBackground},
+}
+
+// ParseAttribute parses an attribute in format "tag attribute kind".
+//
+// tag is the element name or * for all elements, kind is one of url, url-list, srcset, css and background.
+// For example "img data-src url".
+func ParseAttribute(s string) (Attribute, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 3 {
+		return Attribute{}, fmt.Errorf("attribute %q must have format \"tag attribute kind\"", s)
+	}
+	attr := Attribute{
+		Tag:  strings.ToLower(fields[0]),
+		Name: strings.ToLower(fields[1]),
+	}
+	for kind, name := range attributeKindNames {
+		if fields[2] == name {
+			attr.Kind = AttributeKind(kind)
+			return attr, nil
+		}
+	}
+	return Attribute{}, fmt.Errorf("attribute %q: unknown kind %q", s, fields[2])
+}
+
+// ParseAttributes parses attributes in the format accepted by ParseAttribute, one per line.
+// Empty lines and lines starting with # are ignored.
+func ParseAttributes(r io.Reader) ([]Attribute, error) {
+	var attrs []Attribute
+	err := rules.ParseLines(r, func(line string) error {
+		attr, err := ParseAttribute(line)
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, attr)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// attributeKindHandlers are handlers of extra attributes by kind.
+var attributeKindHandlers = [...]attrHandler{
+	AttributeURL:        urlAttribute,
+	AttributeURLList:    urlListAttribute(" "),
+	AttributeSrcSet:     srcSetAttribute,
+	AttributeCSS:        styleAttribute,
+	AttributeBackground: backgroundAttribute,
+}
+
+// newExtraHandlers returns a map[attrName]map[tagName]attrHandler for attrs.
+func newExtraHandlers(attrs []Attribute) map[string]map[string]attrHandler {
+	if len(attrs) == 0 {
+		return nil
+	}
+	m := make(map[string]map[string]attrHandler, len(attrs))
+	for _, attr := range attrs {
+		if int(attr.Kind) >= len(attributeKindHandlers) {
+			continue
+		}
+		if m[attr.Name] == nil {
+			m[attr.Name] = make(map[string]attrHandler)
+		}
+		m[attr.Name][attr.Tag] = attributeKindHandlers[attr.Kind]
+	}
+	return m
+}
@@ -0,0 +1,99 @@
+package rewrite
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tdewolff/parse/v2"
+)
+
+func TestParseAttribute(t *testing.T) {
+	attr, err := ParseAttribute("  IMG  data-src   url ")
+	require.NoError(t, err)
+	assert.Equal(t, Attribute{Tag: "img", Name: "data-src", Kind: AttributeURL}, attr)
+
+	attr, err = ParseAttribute("* data-bg css")
+	require.NoError(t, err)
+	assert.Equal(t, Attribute{Tag: "*", Name: "data-bg", Kind: AttributeCSS}, attr)
+
+	attr, err = ParseAttribute("div data-bg background")
+	require.NoError(t, err)
+	assert.Equal(t, Attribute{Tag: "div", Name: "data-bg", Kind: AttributeBackground}, attr)
+
+	_, err = ParseAttribute("img data-src")
+	assert.Error(t, err)
+	_, err = ParseAttribute("img data-src image")
+	assert.Error(t, err)
+}
+
+func TestParseAttributes(t *testing.T) {
+	attrs, err := ParseAttributes(strings.NewReader("# lazy images\nimg data-src url\n\nsource data-srcset srcset\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []Attribute{
+		{Tag: "img", Name: "data-src", Kind: AttributeURL},
+		{Tag: "source", Name: "data-srcset", Kind: AttributeSrcSet},
+	}, attrs)
+
+	_, err = ParseAttributes(strings.NewReader("img data-src url\nimg\n"))
+	assert.EqualError(t, err, `line 2: attribute "img" must have format "tag attribute kind"`)
+}
+
+func TestHTML5_Attributes(t *testing.T) {
+	input := `<img src="a.png" data-src="b.png" data-srcset="c.png 1x, d.png 2x">` +
+		`<div data-bg="url(e.png)" data-src="f.png" data-urls="g.png h.png"></div>`
+	output := `<img src="/new/a.png" data-src="/new/b.png" data-srcset="/new/c.png 1x, /new/d.png 2x">` +
+		`<div data-bg="url(&#34;/new/e.png&#34;)" data-src="f.png" data-urls="/new/g.png /new/h.png"></div>`
+	opts := &Options{
+		Attributes: []Attribute{
+			{Tag: "*", Name: "data-src", Kind: AttributeURL},
+			{Tag: "*", Name: "data-srcset", Kind: AttributeSrcSet},
+			{Tag: "*", Name: "data-bg", Kind: AttributeCSS},
+			{Tag: "div", Name: "data-urls", Kind: AttributeURLList},
+			// Later attributes override earlier ones.
+			{Tag: "div", Name: "data-src", Kind: AttributeURL},
+			{Tag: "div", Name: "data-src", Kind: AttributeCSS},
+		},
+	}
+	var urls []URL
+	var buf bytes.Buffer
+	err := HTML5(parse.NewInputString(input), &buf, func(url URL) (string, error) {
+		urls = append(urls, url)
+		return "/new/" + url.Value, nil
+	}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, output, buf.String())
+	assert.Equal(t, []URL{
+		{Value: "a.png", Tag: "img", Attribute: "src"},
+		{Value: "b.png", Tag: "img", Attribute: "data-src"},
+		{Value: "c.png", Tag: "img", Attribute: "data-srcset"},
+		{Value: "d.png", Tag: "img", Attribute: "data-srcset"},
+		{Value: "e.png", Type: URLTypeCSS, Tag: "div", Attribute: "data-bg"},
+		{Value: "g.png", Tag: "div", Attribute: "data-urls"},
+		{Value: "h.png", Tag: "div", Attribute: "data-urls"},
+	}, urls)
+}
+
+func TestHTML5_LazyLoadAttributes(t *testing.T) {
+	input := `<div data-bg="/img/a.jpg"></div><div data-bg="url(b.jpg), linear-gradient(red, blue)"></div>` +
+		`<div data-bg=""></div><img data-src="c.jpg" data-srcset="d.jpg 2x">`
+	output := `<div data-bg="/new//img/a.jpg"></div>` +
+		`<div data-bg="url(&#34;/new/b.jpg&#34;), linear-gradient(red, blue)"></div>` +
+		`<div data-bg=""></div><img data-src="/new/c.jpg" data-srcset="/new/d.jpg 2x">`
+	var urls []URL
+	var buf bytes.Buffer
+	err := HTML5(parse.NewInputString(input), &buf, func(url URL) (string, error) {
+		urls = append(urls, url)
+		return "/new/" + url.Value, nil
+	}, &Options{Attributes: LazyLoadAttributes})
+	require.NoError(t, err)
+	assert.Equal(t, output, buf.String())
+	assert.Equal(t, []URL{
+		{Value: "/img/a.jpg", Tag: "div", Attribute: "data-bg"},
+		{Value: "b.jpg", Type: URLTypeCSS, Tag: "div", Attribute: "data-bg"},
+		{Value: "c.jpg", Tag: "img", Attribute: "data-src"},
+		{Value: "d.jpg", Tag: "img", Attribute: "data-srcset"},
+	}, urls)
+}
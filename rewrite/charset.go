@@ -0,0 +1,186 @@
+package rewrite
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/html"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// prescanLength is the number of bytes of an HTML document searched for <meta charset>.
+// https://html.spec.whatwg.org/multipage/parsing.html#prescan-a-byte-stream-to-determine-its-encoding
+const prescanLength = 1024
+
+// isSupportedCharset returns whether documents in the charset (as in the charset parameter of Content-Type)
+// can be rewritten. Empty charset is supported.
+//
+// Charsets mapped to the replacement encoding are not supported, decoding them would replace the whole document
+// with U+FFFD.
+func isSupportedCharset(charset string) bool {
+	if charset == "" {
+		return true
+	}
+	enc, err := htmlindex.Get(charset)
+	return err == nil && enc != encoding.Replacement
+}
+
+// documentEncoding determines the character encoding of document data.
+//
+// The encoding is determined from byte order mark, the charset parameter of Content-Type,
+// <meta charset> in HTML, @charset in CSS or XML declaration in SVG and feeds, in that order.
+// If none of these is present, UTF-8 is assumed.
+// Returns nil if the document is encoded in UTF-8, possibly with a byte order mark.
+func documentEncoding(mediaType string, mediaParams map[string]string, data []byte) (encoding.Encoding, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xEF\xBB\xBF")):
+		return nil, nil
+	case bytes.HasPrefix(data, []byte("\xFE\xFF")):
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), nil
+	case bytes.HasPrefix(data, []byte("\xFF\xFE")):
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), nil
+	}
+	label := mediaParams["charset"]
+	declared := false
+	if label == "" {
+		declared = true
+		switch mediaType {
+		case "text/html":
+			label = htmlMetaCharset(data)
+		case "text/css":
+			label = cssCharset(data)
+		case "image/svg+xml":
+			label = xmlDeclarationEncoding(data)
+		default:
+			if isFeedMediaType(mediaType) {
+				label = xmlDeclarationEncoding(data)
+			}
+		}
+	}
+	if label == "" {
+		return nil, nil
+	}
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q: %v", label, err)
+	}
+	if enc == encoding.Replacement {
+		return nil, fmt.Errorf("unsupported charset %q", label)
+	}
+	if enc == unicode.UTF8 {
+		return nil, nil
+	}
+	if name, _ := htmlindex.Name(enc); declared && strings.HasPrefix(name, "utf-16") {
+		// A document declaring UTF-16 in ASCII-compatible bytes can't be UTF-16.
+		// https://html.spec.whatwg.org/multipage/parsing.html#prescan-a-byte-stream-to-determine-its-encoding
+		return nil, nil
+	}
+	return enc, nil
+}
+
+// htmlMetaCharset returns charset declared in <meta> element at the beginning of HTML data.
+func htmlMetaCharset(data []byte) string {
+	if len(data) > prescanLength {
+		data = data[:prescanLength]
+	}
+	lexer := html.NewLexer(parse.NewInputBytes(data))
+	inMeta := false
+	var httpEquivContentType bool
+	var content string
+	for {
+		tt, _ := lexer.Next()
+		switch tt {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken:
+			inMeta = bytes.Equal(lexer.Text(), []byte("meta"))
+			httpEquivContentType = false
+			content = ""
+		case html.AttributeToken:
+			if !inMeta {
+				continue
+			}
+			value := strings.TrimSpace(unquoteAttrVal(lexer.AttrVal()))
+			switch string(lexer.Text()) {
+			case "charset":
+				return value
+			case "http-equiv":
+				httpEquivContentType = strings.EqualFold(value, "content-type")
+			case "content":
+				content = value
+			}
+		case html.StartTagCloseToken, html.StartTagVoidToken:
+			if inMeta && httpEquivContentType && content != "" {
+				_, params, err := mime.ParseMediaType(content)
+				if err == nil && params["charset"] != "" {
+					return params["charset"]
+				}
+			}
+			inMeta = false
+		}
+	}
+}
+
+func unquoteAttrVal(value []byte) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	return string(value)
+}
+
+// cssCharset returns charset declared by @charset rule at the beginning of CSS data.
+// https://drafts.csswg.org/css-syntax-3/#determine-the-fallback-encoding
+func cssCharset(data []byte) string {
+	prefix := []byte(`@charset "`)
+	if !bytes.HasPrefix(data, prefix) {
+		return ""
+	}
+	data = data[len(prefix):]
+	end := bytes.Index(data, []byte(`";`))
+	if end < 0 {
+		return ""
+	}
+	return string(data[:end])
+}
+
+// xmlDeclarationEncodingRe matches encoding in XML declaration.
+var xmlDeclarationEncodingRe = regexp.MustCompile(`^<\?xml\s[^>]*\bencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// xmlDeclarationEncoding returns encoding declared in XML declaration at the beginning of XML data.
+func xmlDeclarationEncoding(data []byte) string {
+	m := xmlDeclarationEncodingRe.FindSubmatch(data)
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
+
+// rewriteEncoded decodes data from enc to UTF-8, rewrites it using rewriteUTF8 and encodes the result back to enc.
+// Characters that can't be encoded in enc are written as HTML character references if escapeHTML is true.
+func rewriteEncoded(enc encoding.Encoding, data []byte, w io.Writer, escapeHTML bool,
+	rewriteUTF8 func(input *parse.Input, w io.Writer) error) error {
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return err
+	}
+	encoder := enc.NewEncoder()
+	if escapeHTML {
+		encoder = encoding.HTMLEscapeUnsupported(encoder)
+	}
+	ew := encoder.Writer(w)
+	err = rewriteUTF8(parse.NewInputBytes(decoded), ew)
+	if err != nil {
+		return err
+	}
+	if closer, ok := ew.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
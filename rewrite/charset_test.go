@@ -0,0 +1,164 @@
+package rewrite
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tdewolff/parse/v2"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestDocument_Charset(t *testing.T) {
+	tests := []struct {
+		name        string
+		mediaType   string
+		mediaParams map[string]string
+		input       []byte
+		output      []byte
+		urls        []string
+		// prefix is prepended to URLs, /new/ if empty.
+		prefix string
+	}{
+		{
+			name:        "content-type windows-1250",
+			mediaType:   "text/html",
+			mediaParams: map[string]string{"charset": "windows-1250"},
+			// <a href="žluťoučký.html">Kůň</a>
+			input:  []byte("<a href=\"\x9Elu\x9Dou\xE8k\xFD.html\">K\xF9\xF2</a>"),
+			output: []byte("<a href=\"/new/\x9Elu\x9Dou\xE8k\xFD.html\">K\xF9\xF2</a>"),
+			urls:   []string{"žluťoučký.html"},
+		},
+		{
+			name:        "meta charset iso-8859-2",
+			mediaType:   "text/html",
+			mediaParams: map[string]string{},
+			input:       []byte("<meta charset=\"iso-8859-2\"><a href=\"\xB9.html\">\xB9</a>"),
+			output:      []byte("<meta charset=\"iso-8859-2\"><a href=\"/new/\xB9.html\">\xB9</a>"),
+			urls:        []string{"š.html"},
+		},
+		{
+			name:        "meta http-equiv",
+			mediaType:   "text/html",
+			mediaParams: map[string]string{},
+			input: []byte("<meta http-equiv=\"Content-Type\" content=\"text/html; charset=windows-1250\">" +
+				"<a href=\"\x9A.html\">"),
+			output: []byte("<meta http-equiv=\"Content-Type\" content=\"text/html; charset=windows-1250\">" +
+				"<a href=\"/new/\x9A.html\">"),
+			urls: []string{"š.html"},
+		},
+		{
+			name:        "unencodable characters are escaped in html",
+			mediaType:   "text/html",
+			mediaParams: map[string]string{"charset": "iso-8859-2"},
+			input:       []byte("<a href=\"a.html\">"),
+			output:      []byte("<a href=\"/&#8364;/a.html\">"),
+			urls:        []string{"a.html"},
+			prefix:      "/€/",
+		},
+		{
+			name:        "css charset",
+			mediaType:   "text/css",
+			mediaParams: map[string]string{},
+			input:       []byte("@charset \"windows-1250\";\nbody { background: url(\"\x9A.png\") }"),
+			output:      []byte("@charset \"windows-1250\";\nbody { background: url(\"/new/\x9A.png\") }"),
+			urls:        []string{"š.png"},
+		},
+		{
+			name:        "meta utf-16 is utf-8",
+			mediaType:   "text/html",
+			mediaParams: map[string]string{},
+			input:       []byte("<meta charset=\"utf-16\"><a href=\"\xC5\xA1.html\">"),
+			output:      []byte("<meta charset=\"utf-16\"><a href=\"/new/\xC5\xA1.html\">"),
+			urls:        []string{"š.html"},
+		},
+		{
+			name:        "content-type overrides meta",
+			mediaType:   "text/html",
+			mediaParams: map[string]string{"charset": "utf-8"},
+			input:       []byte("<meta charset=\"iso-8859-2\"><a href=\"\xC5\xA1.html\">"),
+			output:      []byte("<meta charset=\"iso-8859-2\"><a href=\"/new/\xC5\xA1.html\">"),
+			urls:        []string{"š.html"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var urls []string
+			var buf bytes.Buffer
+			err := Document(test.mediaType, test.mediaParams, parse.NewInputBytes(test.input), &buf,
+				func(url URL) (string, error) {
+					urls = append(urls, url.Value)
+					prefix := test.prefix
+					if prefix == "" {
+						prefix = "/new/"
+					}
+					return prefix + url.Value, nil
+				}, nil)
+			require.NoError(t, err)
+			assert.Equal(t, test.output, buf.Bytes())
+			assert.Equal(t, test.urls, urls)
+		})
+	}
+}
+
+func TestDocument_UTF16BOM(t *testing.T) {
+	enc := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+	input, err := enc.NewEncoder().Bytes([]byte("<a href=\"č.html\">"))
+	require.NoError(t, err)
+	expected, err := enc.NewEncoder().Bytes([]byte("<a href=\"/new/č.html\">"))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	err = Document("text/html", map[string]string{}, parse.NewInputBytes(input), &buf,
+		func(url URL) (string, error) {
+			assert.Equal(t, "č.html", url.Value)
+			return "/new/" + url.Value, nil
+		}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, expected, buf.Bytes())
+}
+
+func TestDocument_UTF16Header(t *testing.T) {
+	enc := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	input, err := enc.NewEncoder().Bytes([]byte("<a href=\"č.html\">"))
+	require.NoError(t, err)
+	expected, err := enc.NewEncoder().Bytes([]byte("<a href=\"/new/č.html\">"))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	err = Document("text/html", map[string]string{"charset": "utf-16"}, parse.NewInputBytes(input), &buf,
+		func(url URL) (string, error) {
+			assert.Equal(t, "č.html", url.Value)
+			return "/new/" + url.Value, nil
+		}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, expected, buf.Bytes())
+}
+
+func TestDocument_ReplacementCharset(t *testing.T) {
+	input := []byte("<meta charset=\"iso-2022-kr\"><a href=\"a.html\">")
+	var buf bytes.Buffer
+	err := Document("text/html", map[string]string{}, parse.NewInputBytes(input), &buf,
+		func(url URL) (string, error) {
+			return "/new/" + url.Value, nil
+		}, nil)
+	assert.Error(t, err)
+
+	err = Document("text/html", map[string]string{"charset": "iso-2022-kr"}, parse.NewInputBytes(input), &buf,
+		func(url URL) (string, error) {
+			return "/new/" + url.Value, nil
+		}, nil)
+	assert.Error(t, err)
+}
+
+func TestIsSupportedMediaType_Charset(t *testing.T) {
+	assert.True(t, IsSupportedMediaType("text/html", map[string]string{}))
+	assert.True(t, IsSupportedMediaType("text/html", map[string]string{"charset": "UTF-8"}))
+	assert.True(t, IsSupportedMediaType("text/html", map[string]string{"charset": "windows-1250"}))
+	assert.True(t, IsSupportedMediaType("text/css", map[string]string{"charset": "shift_jis"}))
+	assert.False(t, IsSupportedMediaType("text/html", map[string]string{"charset": "x-unknown"}))
+	// Charsets decoded by the replacement encoding are copied verbatim instead of being rewritten.
+	for _, charset := range []string{"iso-2022-kr", "csiso2022kr", "hz-gb-2312", "iso-2022-cn", "iso-2022-cn-ext"} {
+		assert.False(t, IsSupportedMediaType("text/html", map[string]string{"charset": charset}), charset)
+	}
+	assert.False(t, IsSupportedMediaType("image/png", map[string]string{}))
+}
@@ -14,6 +14,10 @@ import (
 	"github.com/tdewolff/parse/v2"
 )
 
+// CSS rewrites URLs in CSS present in input and writes output to w.
+//
+// URLs are found in url() tokens, in strings of @import, image-set(), -webkit-image-set() and src() functions
+// (used in @font-face src) and in @namespace rules. Namespace names are passed with URLTypeCSSNamespace.
 func CSS(input *parse.Input, w io.Writer, rewriter URLRewriter, isInline bool) error {
 	l := css.NewLexer(input)
 	lc := &cssRewriter{
@@ -28,21 +32,32 @@ func CSS(input *parse.Input, w io.Writer, rewriter URLRewriter, isInline bool) e
 		case css.ErrorToken:
 			return ignoreEOF(l.Err())
 		case css.URLToken:
-			err := lc.handleURLToken(text)
+			err := lc.handleURLToken(text, URLTypeCSS)
 			if err != nil {
 				return err
 			}
 		case css.AtKeywordToken:
-			if bytes.EqualFold(text, []byte("@import")) {
-				err := lc.processImport()
-				if err != nil {
-					return err
-				}
+			var err error
+			switch {
+			case bytes.EqualFold(text, []byte("@import")):
+				err = lc.processImport()
+			case bytes.EqualFold(text, []byte("@namespace")):
+				err = lc.processNamespace()
+			default:
+				err = lc.copy()
+			}
+			if err != nil {
+				return err
+			}
+		case css.FunctionToken:
+			var err error
+			if isCSSURLStringFunction(text) {
+				err = lc.processURLStringFunction()
 			} else {
-				err := lc.copy()
-				if err != nil {
-					return err
-				}
+				err = lc.copy()
+			}
+			if err != nil {
+				return err
 			}
 		default:
 			err := lc.copy()
@@ -122,39 +137,123 @@ func (lc *cssRewriter) processImport() error {
 	case css.ErrorToken:
 		return lc.err()
 	case css.StringToken:
-		value, size, quote, err := cssUnescapeString(text)
-		if err != nil {
-			return err
-		}
-		if size != len(text) {
-			return fmt.Errorf("string does not span whole string token")
+		return lc.handleStringToken(text, URLTypeCSS)
+	case css.URLToken:
+		return lc.handleURLToken(text, URLTypeCSS)
+	default:
+		// unexpected, go back to regular handling
+		lc.pushBack()
+		return nil
+	}
+}
+
+// processNamespace handles @namespace rule with optional prefix followed by a string or url().
+// https://drafts.csswg.org/css-namespaces/#syntax
+func (lc *cssRewriter) processNamespace() error {
+	// copy the @namespace token
+	err := lc.copy()
+	if err != nil {
+		return err
+	}
+	for {
+		tt, text := lc.next()
+		switch tt {
+		case css.ErrorToken:
+			return lc.err()
+		case css.WhitespaceToken, css.CommentToken, css.IdentToken:
+			err = lc.copy()
+			if err != nil {
+				return err
+			}
+		case css.StringToken:
+			return lc.handleStringToken(text, URLTypeCSSNamespace)
+		case css.URLToken:
+			return lc.handleURLToken(text, URLTypeCSSNamespace)
+		default:
+			// unexpected, go back to regular handling
+			lc.pushBack()
+			return nil
 		}
-		newValue, err := lc.urlRewriter(URL{
-			Value: value,
-			Type:  URLTypeCSS,
-		})
-		switch {
-		case errors.Is(err, ErrNotModified):
-			return lc.copy()
-		case err != nil:
-			return err
+	}
+}
+
+// isCSSURLStringFunction returns whether function token starts a function with URLs in string arguments.
+func isCSSURLStringFunction(text []byte) bool {
+	name := parse.ToLower(parse.Copy(text))
+	return bytes.Equal(name, []byte("image-set(")) || bytes.Equal(name, []byte("-webkit-image-set(")) ||
+		bytes.Equal(name, []byte("src("))
+}
+
+// processURLStringFunction handles arguments of image-set() and src() functions.
+// Strings that are direct arguments of the function are URLs, strings in nested functions like type() are not.
+// https://drafts.csswg.org/css-images-4/#image-set-notation
+// https://drafts.csswg.org/css-values-4/#urls
+func (lc *cssRewriter) processURLStringFunction() error {
+	// copy the function token
+	err := lc.copy()
+	if err != nil {
+		return err
+	}
+	depth := 0
+	for {
+		tt, text := lc.next()
+		switch tt {
+		case css.ErrorToken:
+			return lc.err()
+		case css.FunctionToken, css.LeftParenthesisToken:
+			depth++
+			err = lc.copy()
+		case css.RightParenthesisToken:
+			err = lc.copy()
+			if depth == 0 {
+				return err
+			}
+			depth--
+		case css.StringToken:
+			if depth == 0 {
+				err = lc.handleStringToken(text, URLTypeCSS)
+			} else {
+				err = lc.copy()
+			}
+		case css.URLToken:
+			err = lc.handleURLToken(text, URLTypeCSS)
+		default:
+			err = lc.copy()
 		}
-		escaped, err := cssEscapeString(newValue, quote)
 		if err != nil {
 			return err
 		}
-		_, err = lc.w.Write(escaped)
+	}
+}
+
+// handleStringToken rewrites a string token containing a URL.
+func (lc *cssRewriter) handleStringToken(text []byte, urlType URLType) error {
+	value, size, quote, err := cssUnescapeString(text)
+	if err != nil {
+		return err
+	}
+	if size != len(text) {
+		return fmt.Errorf("string does not span whole string token")
+	}
+	newValue, err := lc.urlRewriter(URL{
+		Value: value,
+		Type:  urlType,
+	})
+	switch {
+	case errors.Is(err, ErrNotModified):
+		return lc.copy()
+	case err != nil:
 		return err
-	case css.URLToken:
-		return lc.handleURLToken(text)
-	default:
-		// unexpected, go back to regular handling
-		lc.pushBack()
-		return nil
 	}
+	escaped, err := cssEscapeString(newValue, quote)
+	if err != nil {
+		return err
+	}
+	_, err = lc.w.Write(escaped)
+	return err
 }
 
-func (lc *cssRewriter) handleURLToken(text []byte) error {
+func (lc *cssRewriter) handleURLToken(text []byte, urlType URLType) error {
 	if len(text) < 5 {
 		return fmt.Errorf("unexpected token length for %q", text)
 	}
@@ -194,7 +293,7 @@ func (lc *cssRewriter) handleURLToken(text []byte) error {
 	}
 	newURL, err := lc.urlRewriter(URL{
 		Value: urlValue,
-		Type:  URLTypeCSS,
+		Type:  urlType,
 	})
 	switch {
 	case errors.Is(err, ErrNotModified):
@@ -1,10 +1,12 @@
 package rewrite
 
 import (
+	"io/ioutil"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/tdewolff/parse/v2"
 )
 
@@ -64,6 +66,41 @@ func TestCSS(t *testing.T) {
 			output: "@import url(\"https://example.net/newimg.png\") print; " +
 				"body { background: url(\"https://example.net/newimg.png\"); }",
 		},
+		{
+			name:  "image-set strings",
+			input: "div { background-image: image-set(\"a.png\" 1x, 'b.png' 2x); }",
+			output: "div { background-image: image-set(\"https://example.net/newimg.png\" 1x, " +
+				"'https://example.net/newimg.png' 2x); }",
+		},
+		{
+			name:  "webkit image-set",
+			input: "div { background-image: -webkit-image-set(url(a.png) 1x, \"b.png\" 2x); }",
+			output: "div { background-image: -webkit-image-set(url(\"https://example.net/newimg.png\") 1x, " +
+				"\"https://example.net/newimg.png\" 2x); }",
+		},
+		{
+			name:  "image-set type",
+			input: "div { background-image: IMAGE-SET(\"a.avif\" type(\"image/avif\"), \"a.jpg\" type(\"image/jpeg\")); }",
+			output: "div { background-image: IMAGE-SET(\"https://example.net/newimg.png\" type(\"image/avif\"), " +
+				"\"https://example.net/newimg.png\" type(\"image/jpeg\")); }",
+		},
+		{
+			name: "font-face src",
+			input: "@font-face { font-family: \"A\"; src: local(\"A Regular\"), url(a.woff2) format(\"woff2\"), " +
+				"src(\"a.woff\") format(\"woff\"); } p { content: \"a.png\"; }",
+			output: "@font-face { font-family: \"A\"; src: local(\"A Regular\"), url(\"https://example.net/newimg.png\") " +
+				"format(\"woff2\"), src(\"https://example.net/newimg.png\") format(\"woff\"); } p { content: \"a.png\"; }",
+		},
+		{
+			name:   "namespace string",
+			input:  "@namespace svg \"http://www.w3.org/2000/svg\"; svg|a { color: red }",
+			output: "@namespace svg \"https://example.net/newimg.png\"; svg|a { color: red }",
+		},
+		{
+			name:   "namespace url",
+			input:  "@namespace url(http://www.w3.org/1999/xhtml);",
+			output: "@namespace url(\"https://example.net/newimg.png\");",
+		},
 	}
 	for _, test := range tests {
 		test := test
@@ -95,3 +132,22 @@ func TestCSS(t *testing.T) {
 		})
 	}
 }
+
+func TestCSS_URLTypes(t *testing.T) {
+	input := "@namespace svg \"http://www.w3.org/2000/svg\"; @namespace url(http://www.w3.org/1999/xhtml);" +
+		"div { background: image-set(\"a.png\" 1x) }"
+	var urls []URL
+	rewriter := func(url URL) (string, error) {
+		urls = append(urls, url)
+		return "", ErrNotModified
+	}
+	err := CSS(parse.NewInputString(input), ioutil.Discard, rewriter, false)
+	require.NoError(t, err)
+	assert.Equal(t, []URL{
+		{Value: "http://www.w3.org/2000/svg", Type: URLTypeCSSNamespace},
+		{Value: "http://www.w3.org/1999/xhtml", Type: URLTypeCSSNamespace},
+		{Value: "a.png", Type: URLTypeCSS},
+	}, urls)
+	assert.False(t, urls[0].IsFetchable())
+	assert.True(t, urls[2].IsFetchable())
+}
@@ -0,0 +1,97 @@
+package rewrite
+
+import (
+	"io"
+	"strings"
+
+	"github.com/tdewolff/parse/v2"
+)
+
+// feedURLAttributes maps local names of elements to local names of their attributes containing URLs.
+var feedURLAttributes = map[string]string{
+	"link":      "href", // Atom <link href> and <atom:link href> in RSS
+	"enclosure": "url",  // RSS
+	"content":   "url",  // <media:content url>
+	"thumbnail": "url",  // <media:thumbnail url>
+}
+
+// isFeedURLElement returns whether text content of element with the local name is a URL.
+func isFeedURLElement(name string) bool {
+	switch name {
+	case "link", "guid", "loc":
+		return true
+	default:
+		return false
+	}
+}
+
+// Feed rewrites RSS, Atom or sitemap XML document present in input, replace links with the result of urlRewriter
+// and write output to w.
+//
+// URLs in text of <link>, <loc> and <guid> (unless isPermaLink is false) elements are rewritten,
+// as well as attributes href of <link>, url of <enclosure> and url of <media:content> and <media:thumbnail>.
+func Feed(input *parse.Input, w io.Writer, urlRewriter URLRewriter) error {
+	return rewriteXML(input, w, &feedPolicy{urlRewriter: urlRewriter})
+}
+
+type feedPolicy struct {
+	urlRewriter URLRewriter
+	// currentTag is the local name of the last start tag.
+	currentTag string
+	// textTag is the local name of the element whose text content is a URL, empty outside of such elements.
+	textTag string
+	// notPermaLink is true if the current element has isPermaLink="false" attribute.
+	notPermaLink bool
+}
+
+func (p *feedPolicy) startTag(name string) {
+	p.currentTag = name
+	p.textTag = ""
+	p.notPermaLink = false
+}
+
+func (p *feedPolicy) startTagClose() {
+	if isFeedURLElement(p.currentTag) && !(p.currentTag == "guid" && p.notPermaLink) {
+		p.textTag = p.currentTag
+	}
+}
+
+func (p *feedPolicy) endTag() {
+	p.textTag = ""
+}
+
+func (p *feedPolicy) attribute(name, value string) (string, error) {
+	if p.currentTag == "guid" && name == "isPermaLink" && strings.TrimSpace(value) == "false" {
+		p.notPermaLink = true
+		return "", ErrNotModified
+	}
+	if feedURLAttributes[p.currentTag] != localName([]byte(name)) {
+		return "", ErrNotModified
+	}
+	return p.urlRewriter(URL{
+		Value:     value,
+		Tag:       p.currentTag,
+		Attribute: name,
+	})
+}
+
+func (p *feedPolicy) rewritesText() bool {
+	return p.textTag != ""
+}
+
+// text returns the rewritten URL in text, keeping whitespace around it.
+func (p *feedPolicy) text(text string) (string, error) {
+	value := strings.TrimSpace(text)
+	if value == "" {
+		return "", ErrNotModified
+	}
+	start := strings.Index(text, value)
+	newValue, err := p.urlRewriter(URL{
+		Value: value,
+		Tag:   p.textTag,
+	})
+	if err != nil {
+		return "", err
+	}
+	return text[:start] + newValue + text[start+len(value):], nil
+}
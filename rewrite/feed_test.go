@@ -0,0 +1,96 @@
+package rewrite
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tdewolff/parse/v2"
+)
+
+func TestFeed(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output string
+		urls   []URL
+	}{
+		{
+			name: "rss",
+			input: `<?xml version="1.0"?><rss xmlns:atom="http://www.w3.org/2005/Atom" ` +
+				`xmlns:media="http://search.yahoo.com/mrss/"><channel>` +
+				`<atom:link href="http://a/feed" rel="self"/><link>http://a/</link>` +
+				`<item><title>http://a/title</title><link> http://a/1?x=1&amp;y=2 </link>` +
+				`<guid>http://a/1</guid><guid isPermaLink="false">tag:a,1</guid>` +
+				`<enclosure url='http://a/1.mp3' length="1" type="audio/mpeg"/>` +
+				`<media:content url="http://a/1.jpg" medium="image"><media:thumbnail url="http://a/t.jpg"/>` +
+				`</media:content><link><![CDATA[http://a/2]]></link></item></channel></rss>`,
+			output: `<?xml version="1.0"?><rss xmlns:atom="http://www.w3.org/2005/Atom" ` +
+				`xmlns:media="http://search.yahoo.com/mrss/"><channel>` +
+				`<atom:link href="/new/http://a/feed" rel="self"/><link>/new/http://a/</link>` +
+				`<item><title>http://a/title</title><link> /new/http://a/1?x=1&amp;y=2 </link>` +
+				`<guid>/new/http://a/1</guid><guid isPermaLink="false">tag:a,1</guid>` +
+				`<enclosure url='/new/http://a/1.mp3' length="1" type="audio/mpeg"/>` +
+				`<media:content url="/new/http://a/1.jpg" medium="image"><media:thumbnail url="/new/http://a/t.jpg"/>` +
+				`</media:content><link><![CDATA[/new/http://a/2]]></link></item></channel></rss>`,
+			urls: []URL{
+				{Value: "http://a/feed", Tag: "link", Attribute: "href"},
+				{Value: "http://a/", Tag: "link"},
+				{Value: "http://a/1?x=1&y=2", Tag: "link"},
+				{Value: "http://a/1", Tag: "guid"},
+				{Value: "http://a/1.mp3", Tag: "enclosure", Attribute: "url"},
+				{Value: "http://a/1.jpg", Tag: "content", Attribute: "url"},
+				{Value: "http://a/t.jpg", Tag: "thumbnail", Attribute: "url"},
+				{Value: "http://a/2", Tag: "link"},
+			},
+		},
+		{
+			name: "atom",
+			input: `<feed xmlns="http://www.w3.org/2005/Atom"><link href="http://a/"/>` +
+				`<entry><id>http://a/1</id><link rel="alternate" href="http://a/1"></link></entry></feed>`,
+			output: `<feed xmlns="http://www.w3.org/2005/Atom"><link href="/new/http://a/"/>` +
+				`<entry><id>http://a/1</id><link rel="alternate" href="/new/http://a/1"></link></entry></feed>`,
+			urls: []URL{
+				{Value: "http://a/", Tag: "link", Attribute: "href"},
+				{Value: "http://a/1", Tag: "link", Attribute: "href"},
+			},
+		},
+		{
+			name: "sitemap",
+			input: "<urlset xmlns=\"http://www.sitemaps.org/schemas/sitemap/0.9\">\n" +
+				"  <url>\n    <loc>\n      http://a/1\n    </loc>\n    <lastmod>2021-01-01</lastmod>\n  </url>\n</urlset>",
+			output: "<urlset xmlns=\"http://www.sitemaps.org/schemas/sitemap/0.9\">\n" +
+				"  <url>\n    <loc>\n      /new/http://a/1\n    </loc>\n    <lastmod>2021-01-01</lastmod>\n  </url>\n</urlset>",
+			urls: []URL{
+				{Value: "http://a/1", Tag: "loc"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var urls []URL
+			var buf bytes.Buffer
+			err := Feed(parse.NewInputString(test.input), &buf, func(url URL) (string, error) {
+				urls = append(urls, url)
+				return "/new/" + url.Value, nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, test.output, buf.String())
+			assert.Equal(t, test.urls, urls)
+		})
+	}
+}
+
+func TestDocument_FeedEncoding(t *testing.T) {
+	input := "<?xml version=\"1.0\" encoding=\"ISO-8859-2\"?><rss><channel><link>/\xB9</link></channel></rss>"
+	var buf bytes.Buffer
+	err := Document("application/rss+xml", map[string]string{}, parse.NewInputString(input), &buf,
+		func(url URL) (string, error) {
+			assert.Equal(t, "/š", url.Value)
+			return "/new" + url.Value, nil
+		}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<?xml version=\"1.0\" encoding=\"ISO-8859-2\"?><rss><channel><link>/new/\xB9</link></channel></rss>",
+		buf.String())
+}
@@ -0,0 +1,129 @@
+package rewrite
+
+import (
+	"errors"
+	"net/http"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// headerRewriters rewrite URLs in values of HTTP response headers, by canonical header name.
+var headerRewriters = map[string]func(value string, urlRewriter URLRewriter) (string, error){
+	"Link":             linkHeader,
+	"Refresh":          refreshHeader,
+	"Content-Location": wholeValueHeader(URLTypeHTTPContentLocation),
+	"Location":         wholeValueHeader(URLTypeHTTPLocation),
+}
+
+// Headers rewrites URLs in HTTP response headers and returns the rewritten headers.
+//
+// URLs are passed to urlRewriter with a URL type specific to the header they were found in,
+// they are relative to the URL of the response.
+// header is not modified, the returned header shares values of headers without URLs with it.
+func Headers(header http.Header, urlRewriter URLRewriter) (http.Header, error) {
+	out := make(http.Header, len(header))
+	for name, values := range header {
+		rewriteValue := headerRewriters[textproto.CanonicalMIMEHeaderKey(name)]
+		if rewriteValue == nil {
+			out[name] = values
+			continue
+		}
+		newValues := make([]string, len(values))
+		for i, value := range values {
+			newValue, err := rewriteValue(value, urlRewriter)
+			switch {
+			case errors.Is(err, ErrNotModified):
+				newValues[i] = value
+			case err != nil:
+				return nil, err
+			default:
+				newValues[i] = newValue
+			}
+		}
+		out[name] = newValues
+	}
+	return out, nil
+}
+
+func wholeValueHeader(urlType URLType) func(value string, urlRewriter URLRewriter) (string, error) {
+	return func(value string, urlRewriter URLRewriter) (string, error) {
+		if strings.TrimSpace(value) == "" {
+			return "", ErrNotModified
+		}
+		return urlRewriter(URL{Value: value, Type: urlType})
+	}
+}
+
+// linkHeader rewrites URI references in a Link header, keeping the link parameters.
+// https://www.rfc-editor.org/rfc/rfc8288#section-3
+func linkHeader(value string, urlRewriter URLRewriter) (string, error) {
+	var sb strings.Builder
+	anyModified := false
+	i := 0
+	for {
+		start := strings.IndexByte(value[i:], '<')
+		if start < 0 {
+			break
+		}
+		start += i
+		end := strings.IndexByte(value[start:], '>')
+		if end < 0 {
+			break
+		}
+		end += start
+		sb.WriteString(value[i : start+1])
+		reference := value[start+1 : end]
+		newReference, err := urlRewriter(URL{Value: reference, Type: URLTypeHTTPLink})
+		switch {
+		case errors.Is(err, ErrNotModified):
+			sb.WriteString(reference)
+		case err != nil:
+			return "", err
+		default:
+			sb.WriteString(newReference)
+			anyModified = true
+		}
+		// Link parameters may contain < in quoted strings, skip to the next link value.
+		i = end + skipLinkParams(value[end:])
+		sb.WriteString(value[end:i])
+	}
+	if !anyModified {
+		return "", ErrNotModified
+	}
+	sb.WriteString(value[i:])
+	return sb.String(), nil
+}
+
+// skipLinkParams returns the length of link parameters at the start of s, up to and including the comma
+// separating the next link value.
+func skipLinkParams(s string) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quoted && s[i] == '\\':
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == ',':
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// refreshHeaderRegexp matches value of the Refresh header, the URL is the third group.
+// https://html.spec.whatwg.org/multipage/semantics.html#shared-declarative-refresh-steps
+var refreshHeaderRegexp = regexp.MustCompile(`^(\s*[\d.]+\s*[;,]\s*(?:[Uu][Rr][Ll]\s*=\s*)?)(['"]?)(.*?)(['"]?\s*)$`)
+
+func refreshHeader(value string, urlRewriter URLRewriter) (string, error) {
+	m := refreshHeaderRegexp.FindStringSubmatch(value)
+	if m == nil || m[3] == "" {
+		return "", ErrNotModified
+	}
+	newURL, err := urlRewriter(URL{Value: m[3], Type: URLTypeHTTPRefresh})
+	if err != nil {
+		return "", err
+	}
+	return m[1] + m[2] + newURL + m[4], nil
+}
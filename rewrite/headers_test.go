@@ -0,0 +1,52 @@
+package rewrite
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaders(t *testing.T) {
+	header := http.Header{
+		"Link": {
+			`</a.css>; rel=preload; as=style, <https://example.com/b.js>; rel="modulepreload"; title="x,<y>"`,
+			`<c.png>;rel=preload,<d.png>`,
+		},
+		"Refresh":          {"5; URL='/refreshed'", "5"},
+		"Content-Location": {"/index.html"},
+		"Location":         {"/moved"},
+		"Content-Type":     {"text/html"},
+	}
+	var urls []URL
+	out, err := Headers(header, func(url URL) (string, error) {
+		urls = append(urls, url)
+		if strings.HasPrefix(url.Value, "/") {
+			return "/new" + url.Value, nil
+		}
+		return "", ErrNotModified
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.Header{
+		"Link": {
+			`</new/a.css>; rel=preload; as=style, <https://example.com/b.js>; rel="modulepreload"; title="x,<y>"`,
+			`<c.png>;rel=preload,<d.png>`,
+		},
+		"Refresh":          {"5; URL='/new/refreshed'", "5"},
+		"Content-Location": {"/new/index.html"},
+		"Location":         {"/new/moved"},
+		"Content-Type":     {"text/html"},
+	}, out)
+	assert.ElementsMatch(t, []URL{
+		{Value: "/a.css", Type: URLTypeHTTPLink},
+		{Value: "https://example.com/b.js", Type: URLTypeHTTPLink},
+		{Value: "c.png", Type: URLTypeHTTPLink},
+		{Value: "d.png", Type: URLTypeHTTPLink},
+		{Value: "/refreshed", Type: URLTypeHTTPRefresh},
+		{Value: "/index.html", Type: URLTypeHTTPContentLocation},
+		{Value: "/moved", Type: URLTypeHTTPLocation},
+	}, urls)
+	assert.Equal(t, []string{"/moved"}, header["Location"], "input must not be modified")
+}
@@ -14,13 +14,18 @@ import (
 )
 
 // Rewrite HTML5 page present in data, replace links with the result of urlRewriter and write output to w.
-func HTML5(input *parse.Input, w io.Writer, urlRewriter URLRewriter) error {
+// opts may be nil.
+func HTML5(input *parse.Input, w io.Writer, urlRewriter URLRewriter, opts *Options) error {
 	lc := html5Rewriter{
 		input:       input,
 		lexer:       html.NewLexer(input),
 		w:           w,
 		urlRewriter: urlRewriter,
 	}
+	if opts != nil {
+		lc.extraHandlers = newExtraHandlers(opts.Attributes)
+		lc.jsonKeys = opts.JSONKeys
+	}
 	for {
 		tt, _ := lc.next()
 		if tt == html.ErrorToken {
@@ -29,6 +34,7 @@ func HTML5(input *parse.Input, w io.Writer, urlRewriter URLRewriter) error {
 		switch tt {
 		case html.StartTagToken:
 			currentTag := lc.text()
+			lc.currentTag = string(currentTag)
 			err := lc.copy()
 			if err != nil {
 				return err
@@ -60,8 +66,12 @@ func (lc *html5Rewriter) processTag(currentTag []byte) error {
 		return err
 	case bytes.Equal(currentTag, []byte("style")):
 		return lc.processStyleTag(currentTag)
+	case bytes.Equal(currentTag, []byte("script")):
+		return lc.processScriptTag(currentTag)
+	case bytes.Equal(currentTag, []byte("link")):
+		return lc.processLinkTag(currentTag)
 	default:
-		_, err := lc.rewriteAttributes(currentTag, findHandler)
+		_, err := lc.rewriteAttributes(currentTag, lc.findHandler)
 		return err
 	}
 }
@@ -145,7 +155,7 @@ func (lc *html5Rewriter) processMeta() error {
 }
 
 func (lc *html5Rewriter) processStyleTag(currentTag []byte) error {
-	tt, err := lc.rewriteAttributes(currentTag, findHandler)
+	tt, err := lc.rewriteAttributes(currentTag, lc.findHandler)
 	if err != nil {
 		return err
 	}
@@ -169,7 +179,7 @@ func (lc *html5Rewriter) processStyleTag(currentTag []byte) error {
 			var buf bytes.Buffer
 			buf.Grow(len(data))
 			cssData := stdhtml.UnescapeString(string(data))
-			err := CSS(parse.NewInputString(cssData), &buf, lc.urlRewriter, false)
+			err := CSS(parse.NewInputString(cssData), &buf, lc.tagURLRewriter(), false)
 			if err != nil {
 				return err
 			}
@@ -183,6 +193,114 @@ func (lc *html5Rewriter) processStyleTag(currentTag []byte) error {
 	}
 }
 
+func (lc *html5Rewriter) processScriptTag(currentTag []byte) error {
+	attrs, closeTagRaw, err := lc.readAttributes()
+	if err != nil {
+		return err
+	}
+	scriptType := ""
+	for i := range attrs {
+		attr := &attrs[i]
+		if bytes.Equal(attr.attrName, []byte("type")) {
+			_, scriptType, err = attr.cleanValue()
+			if err != nil {
+				return err
+			}
+		}
+		handler := lc.findHandler(currentTag, attr.attrName)
+		if handler == nil {
+			err = attr.copy(lc.w)
+		} else {
+			err = attr.rewrite(lc, handler)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = lc.w.Write(closeTagRaw)
+	if err != nil {
+		return err
+	}
+	if bytes.HasSuffix(closeTagRaw, []byte("/>")) {
+		return nil
+	}
+	tt, data := lc.next()
+	switch {
+	case tt == html.ErrorToken:
+		return lc.err()
+	case tt == html.TextToken && isJavaScriptScriptType(scriptType):
+		// Script content is raw text, it does not contain character references.
+		rewriter := lc.tagURLRewriter()
+		return JavaScript(parse.NewInputBytes(data), lc.w, func(u URL) (string, error) {
+			u.Base = lc.baseURL
+			u.NewBase = lc.newBaseURL
+			return rewriter(u)
+		})
+	case tt == html.TextToken && strings.EqualFold(strings.TrimSpace(scriptType), "importmap"):
+		return importMap(parse.NewInputBytes(data), lc.w, lc.baseURL, lc.newBaseURL, lc.tagURLRewriter())
+	case tt == html.TextToken && strings.EqualFold(strings.TrimSpace(scriptType), "application/ld+json"):
+		rewriter := lc.tagURLRewriter()
+		return rewriteJSON(parse.NewInputBytes(data), lc.w, jsonKeysRewriter(lc.jsonKeys, func(value string) (string, error) {
+			return rewriter(lc.newURL(value, URLTypeJSON))
+		}))
+	default:
+		return lc.copy()
+	}
+}
+
+func (lc *html5Rewriter) processLinkTag(currentTag []byte) error {
+	attrs, closeTagRaw, err := lc.readAttributes()
+	if err != nil {
+		return err
+	}
+	var rel string
+	for i := range attrs {
+		if bytes.Equal(attrs[i].attrName, []byte("rel")) {
+			_, rel, err = attrs[i].cleanValue()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for i := range attrs {
+		attr := &attrs[i]
+		handler := lc.findHandler(currentTag, attr.attrName)
+		switch {
+		case handler != nil && bytes.Equal(attr.attrName, []byte("href")) && hasLinkType(rel, "modulepreload"):
+			handler = modulePreloadHrefAttribute
+		case bytes.Equal(attr.attrName, []byte("imagesrcset")) && hasLinkType(rel, "preload"):
+			handler = srcSetAttribute
+		}
+		if handler == nil {
+			err = attr.copy(lc.w)
+		} else {
+			err = attr.rewrite(lc, handler)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = lc.w.Write(closeTagRaw)
+	return err
+}
+
+// hasLinkType returns whether the space-separated rel attribute value contains linkType.
+func hasLinkType(rel, linkType string) bool {
+	for _, t := range strings.Fields(rel) {
+		if strings.EqualFold(t, linkType) {
+			return true
+		}
+	}
+	return false
+}
+
+// isJavaScriptScriptType returns whether script element with the type attribute contains JavaScript.
+// https://html.spec.whatwg.org/multipage/scripting.html#attr-script-type
+func isJavaScriptScriptType(scriptType string) bool {
+	scriptType = strings.ToLower(strings.TrimSpace(scriptType))
+	return scriptType == "" || scriptType == "module" || isJavaScriptMediaType(scriptType)
+}
+
 var textContentHTMLEscaper = strings.NewReplacer(
 	`&`, "&amp;",
 	`<`, "&lt;",
@@ -260,6 +378,12 @@ type html5Rewriter struct {
 	baseURL, newBaseURL string
 	baseURLSet          bool
 	urlRewriter         URLRewriter
+	// currentTag and currentAttr are names of the tag and attribute being rewritten.
+	currentTag, currentAttr string
+	// extraHandlers is a map[attrName]map[tagName]attrHandler of configured attributes, tagName may be *.
+	extraHandlers map[string]map[string]attrHandler
+	// jsonKeys are keys of URL properties in JSON-LD, nil means DefaultJSONKeys.
+	jsonKeys []string
 }
 
 func (lc *html5Rewriter) next() (html.TokenType, []byte) {
@@ -334,7 +458,9 @@ func (at *attributeToken) rewrite(lc *html5Rewriter, handler attrHandler) error
 		return err
 	}
 
+	lc.currentAttr = string(at.attrName)
 	newString, err := handler(lc, cleanValue)
+	lc.currentAttr = ""
 	switch {
 	case errors.Is(err, ErrNotModified):
 		return at.copy(lc.w)
@@ -405,6 +531,21 @@ func findHandler(tagName, attrName []byte) attrHandler {
 	return attr[string(tagName)]
 }
 
+// findHandler returns handler for the attribute, including the configured ones, or nil.
+func (lc *html5Rewriter) findHandler(tagName, attrName []byte) attrHandler {
+	if handler := findHandler(tagName, attrName); handler != nil {
+		return handler
+	}
+	attr := lc.extraHandlers[string(attrName)]
+	if attr == nil {
+		return nil
+	}
+	if handler := attr[string(tagName)]; handler != nil {
+		return handler
+	}
+	return attr["*"]
+}
+
 type findHandlerFunc func(tagName, attrName []byte) attrHandler
 
 // attributeHandlers is a map[attrName]map[tagName]attrHandler
@@ -425,7 +566,7 @@ var attributeHandlers = map[string]map[string]attrHandler{
 	"codebase":   tags("applet", "object"),
 	"data":       tags("object"),
 	"formaction": tags("button", "input"),
-	"href":       tags("a", "area", "link"), // ignore base for now
+	"href":       tags("a", "area", "link", "image", "use"), // ignore base for now
 	"icon":       tags("command"),
 	"longdesc":   tags("img", "frame", "iframe"),
 	"manifest":   tags("html"),
@@ -436,28 +577,52 @@ var attributeHandlers = map[string]map[string]attrHandler{
 		"img":    srcSetAttribute,
 		"source": srcSetAttribute,
 	},
-	"usemap": tags("img", "input", "object"),
+	"usemap":     tags("img", "input", "object"),
+	"xlink:href": tags("a", "image", "use"), // inline SVG
 }
 
 type attrHandler func(lc *html5Rewriter, attrValue string) (string, error)
 
+// newURL returns URL with the given value found in the current tag and attribute.
+func (lc *html5Rewriter) newURL(value string, urlType URLType) URL {
+	return URL{
+		Value:     value,
+		Base:      lc.baseURL,
+		NewBase:   lc.newBaseURL,
+		Type:      urlType,
+		Tag:       lc.currentTag,
+		Attribute: lc.currentAttr,
+	}
+}
+
+// tagURLRewriter returns urlRewriter that fills in the current tag and attribute for URLs in embedded CSS.
+func (lc *html5Rewriter) tagURLRewriter() URLRewriter {
+	tag, attr := lc.currentTag, lc.currentAttr
+	return func(u URL) (string, error) {
+		u.Tag = tag
+		u.Attribute = attr
+		return lc.urlRewriter(u)
+	}
+}
+
 func urlAttribute(lc *html5Rewriter, attrValue string) (string, error) {
-	return lc.urlRewriter(URL{
-		Value:   attrValue,
-		Base:    lc.baseURL,
-		NewBase: lc.newBaseURL,
-		Type:    URLTypeUnknown,
-	})
+	return lc.urlRewriter(lc.newURL(attrValue, URLTypeUnknown))
+}
+
+func modulePreloadHrefAttribute(lc *html5Rewriter, attrValue string) (string, error) {
+	return lc.urlRewriter(lc.newURL(attrValue, URLTypeJSImport))
 }
 
 func openGraphContentAttribute(lc *html5Rewriter, attrValue string) (string, error) {
 	// OpenGraph URLs are always absolute, they don't obey base.
 	// https://developer.mozilla.org/en-US/docs/Web/HTML/Element/base#open_graph
 	return lc.urlRewriter(URL{
-		Value:   attrValue,
-		Base:    "",
-		NewBase: "",
-		Type:    URLTypeOpenGraph,
+		Value:     attrValue,
+		Base:      "",
+		NewBase:   "",
+		Type:      URLTypeOpenGraph,
+		Tag:       lc.currentTag,
+		Attribute: lc.currentAttr,
 	})
 }
 
@@ -470,12 +635,7 @@ func urlListAttribute(separator string) attrHandler {
 			if i > 0 {
 				buf.WriteString(separator)
 			}
-			rewritten, err := lc.urlRewriter(URL{
-				Value:   part,
-				Base:    lc.baseURL,
-				NewBase: lc.newBaseURL,
-				Type:    URLTypeUnknown,
-			})
+			rewritten, err := lc.urlRewriter(lc.newURL(part, URLTypeUnknown))
 			switch {
 			case errors.Is(err, ErrNotModified):
 				buf.WriteString(part)
@@ -493,46 +653,6 @@ func urlListAttribute(separator string) attrHandler {
 	}
 }
 
-func srcSetAttribute(lc *html5Rewriter, attrValue string) (string, error) {
-	var buf strings.Builder
-	parts := strings.Split(attrValue, ",")
-	anyModified := false
-	for i, part := range parts {
-		if i > 0 {
-			buf.WriteString(", ")
-		}
-		trimmedPart := strings.TrimSpace(part)
-		parts2 := strings.SplitN(trimmedPart, " ", 2)
-		if len(trimmedPart) > 0 && len(parts2) > 0 {
-			rewritten, err := lc.urlRewriter(URL{
-				Value:   parts2[0],
-				Base:    lc.baseURL,
-				NewBase: lc.newBaseURL,
-				Type:    URLTypeUnknown,
-			})
-			switch {
-			case errors.Is(err, ErrNotModified):
-				buf.WriteString(part)
-			case err != nil:
-				return "", err
-			default:
-				buf.WriteString(rewritten)
-				if len(parts2) > 1 {
-					buf.WriteString(" ")
-					buf.WriteString(parts2[1])
-				}
-				anyModified = true
-			}
-		} else {
-			buf.WriteString(part)
-		}
-	}
-	if !anyModified {
-		return "", ErrNotModified
-	}
-	return buf.String(), nil
-}
-
 var refreshRegexp = regexp.MustCompile(`^\s*(\d+)\s*(?:;url=(.*)\s*)?$`)
 
 func httpEquivRefreshAttribute(lc *html5Rewriter, attrValue string) (string, error) {
@@ -540,12 +660,7 @@ func httpEquivRefreshAttribute(lc *html5Rewriter, attrValue string) (string, err
 	if len(m) != 3 {
 		return "", ErrNotModified
 	}
-	newURL, err := lc.urlRewriter(URL{
-		Value:   m[2],
-		Base:    lc.baseURL,
-		NewBase: lc.newBaseURL,
-		Type:    URLTypeUnknown,
-	})
+	newURL, err := lc.urlRewriter(lc.newURL(m[2], URLTypeUnknown))
 	if err != nil {
 		return "", err
 	}
@@ -561,7 +676,12 @@ func baseHrefAttribute(lc *html5Rewriter, attrValue string) (string, error) {
 		return "", ErrNotModified
 	}
 	lc.baseURL = attrValue
-	newBaseURL, err := lc.urlRewriter(URL{Value: attrValue, Type: URLTypeBase})
+	newBaseURL, err := lc.urlRewriter(URL{
+		Value:     attrValue,
+		Type:      URLTypeBase,
+		Tag:       lc.currentTag,
+		Attribute: lc.currentAttr,
+	})
 	switch {
 	case errors.Is(err, ErrNotModified):
 		lc.newBaseURL = lc.baseURL
@@ -576,9 +696,30 @@ func baseHrefAttribute(lc *html5Rewriter, attrValue string) (string, error) {
 
 func styleAttribute(lc *html5Rewriter, attrValue string) (string, error) {
 	var sb strings.Builder
-	err := CSS(parse.NewInputString(attrValue), &sb, lc.urlRewriter, true)
+	err := CSS(parse.NewInputString(attrValue), &sb, lc.tagURLRewriter(), true)
+	if err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// backgroundAttribute handles CSS values like styleAttribute, values without url() are a single URL.
+func backgroundAttribute(lc *html5Rewriter, attrValue string) (string, error) {
+	urlRewriter := lc.tagURLRewriter()
+	anyURL := false
+	var sb strings.Builder
+	err := CSS(parse.NewInputString(attrValue), &sb, func(u URL) (string, error) {
+		anyURL = true
+		return urlRewriter(u)
+	}, true)
 	if err != nil {
 		return "", err
 	}
+	if !anyURL {
+		if strings.TrimSpace(attrValue) == "" {
+			return "", ErrNotModified
+		}
+		return urlAttribute(lc, attrValue)
+	}
 	return sb.String(), nil
 }
@@ -158,7 +158,7 @@ func TestHTML5(t *testing.T) {
 			}
 			input := parse.NewInputBytes(inputData)
 			var output strings.Builder
-			err := HTML5(input, &output, test.urlRewriter)
+			err := HTML5(input, &output, test.urlRewriter, nil)
 			if test.err != "" {
 				assert.EqualError(t, err, test.err)
 			} else {
@@ -377,3 +377,20 @@ func TestSrcSetAttribute(t *testing.T) {
 		})
 	}
 }
+
+func TestHTML5_URLLocation(t *testing.T) {
+	input := `<html><head><link href="a.css" rel="stylesheet"><style>body{background:url(b.png)}</style></head>` +
+		`<body><img src="c.png" style="background: url('d.png')"></body></html>`
+	var urls []URL
+	err := HTML5(parse.NewInputString(input), ioutil.Discard, func(url URL) (string, error) {
+		urls = append(urls, url)
+		return "", ErrNotModified
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []URL{
+		{Value: "a.css", Type: URLTypeUnknown, Tag: "link", Attribute: "href"},
+		{Value: "b.png", Type: URLTypeCSS, Tag: "style"},
+		{Value: "c.png", Type: URLTypeUnknown, Tag: "img", Attribute: "src"},
+		{Value: "d.png", Type: URLTypeCSS, Tag: "img", Attribute: "style"},
+	}, urls)
+}
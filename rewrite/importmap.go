@@ -0,0 +1,57 @@
+package rewrite
+
+import (
+	"io"
+
+	"github.com/tdewolff/parse/v2"
+)
+
+// importMap rewrites URLs in an import map present in input and writes output to w.
+//
+// Addresses, scope prefixes and specifier keys that are URLs are passed to urlRewriter with URLTypeJSImport.
+// Bare specifiers are not URLs and are kept as they are.
+// https://html.spec.whatwg.org/multipage/webappapis.html#import-maps
+func importMap(input *parse.Input, w io.Writer, base, newBase string, urlRewriter URLRewriter) error {
+	return rewriteJSON(input, w, func(path []string, key bool, value string) (string, error) {
+		if !isImportMapURL(path, key, value) {
+			return "", ErrNotModified
+		}
+		return urlRewriter(URL{
+			Value:   value,
+			Base:    base,
+			NewBase: newBase,
+			Type:    URLTypeJSImport,
+		})
+	})
+}
+
+// isImportMapURL returns whether the string at path in the import map is a URL.
+func isImportMapURL(path []string, key bool, value string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	switch path[0] {
+	case "imports":
+		// {"imports": {"specifier": "address"}}
+		switch {
+		case len(path) == 1 && key:
+			return isModuleSpecifierURL(value)
+		case len(path) == 2 && !key:
+			return value != ""
+		}
+	case "scopes":
+		// {"scopes": {"scope prefix": {"specifier": "address"}}}
+		switch {
+		case len(path) == 1 && key:
+			return true
+		case len(path) == 2 && key:
+			return isModuleSpecifierURL(value)
+		case len(path) == 3 && !key:
+			return value != ""
+		}
+	case "integrity":
+		// {"integrity": {"url": "metadata"}}
+		return len(path) == 1 && key && isModuleSpecifierURL(value)
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package rewrite
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tdewolff/parse/v2"
+)
+
+func TestHTML5_ImportMap(t *testing.T) {
+	input := `<script type="importmap">
+{
+  "imports": {
+    "react": "/vendor/react.js",
+    "/app/": "./app/",
+    "lodash": "https://cdn.example.com/lodash.js"
+  },
+  "scopes": {
+    "/legacy/": {"react": "/vendor/react-16.js", "x":1}
+  },
+  "integrity": {"/vendor/react.js": "sha384-abc"}
+}
+</script><link rel="modulepreload" href="/app/main.js"><link rel="stylesheet" href="a.css">`
+	output := `<script type="importmap">
+{
+  "imports": {
+    "react": "/new//vendor/react.js",
+    "/new//app/": "/new/./app/",
+    "lodash": "/new/https://cdn.example.com/lodash.js"
+  },
+  "scopes": {
+    "/new//legacy/": {"react": "/new//vendor/react-16.js", "x":1}
+  },
+  "integrity": {"/new//vendor/react.js": "sha384-abc"}
+}
+</script><link rel="modulepreload" href="/new//app/main.js"><link rel="stylesheet" href="/new/a.css">`
+	var urls []URL
+	var buf bytes.Buffer
+	err := HTML5(parse.NewInputString(input), &buf, func(url URL) (string, error) {
+		urls = append(urls, url)
+		return "/new/" + url.Value, nil
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, output, buf.String())
+	assert.Equal(t, []URL{
+		{Value: "/vendor/react.js", Type: URLTypeJSImport, Tag: "script"},
+		{Value: "/app/", Type: URLTypeJSImport, Tag: "script"},
+		{Value: "./app/", Type: URLTypeJSImport, Tag: "script"},
+		{Value: "https://cdn.example.com/lodash.js", Type: URLTypeJSImport, Tag: "script"},
+		{Value: "/legacy/", Type: URLTypeJSImport, Tag: "script"},
+		{Value: "/vendor/react-16.js", Type: URLTypeJSImport, Tag: "script"},
+		{Value: "/vendor/react.js", Type: URLTypeJSImport, Tag: "script"},
+		{Value: "/app/main.js", Type: URLTypeJSImport, Tag: "link", Attribute: "href"},
+		{Value: "a.css", Tag: "link", Attribute: "href"},
+	}, urls)
+}
+
+func TestImportMap_Invalid(t *testing.T) {
+	input := `{"imports": {"a": "/a.js"}, "scopes": oops}`
+	var buf bytes.Buffer
+	err := importMap(parse.NewInputString(input), &buf, "", "", func(url URL) (string, error) {
+		return "/new" + url.Value, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `{"imports": {"a": "/new/a.js"}, "scopes": oops}`, buf.String())
+}
@@ -0,0 +1,344 @@
+package rewrite
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// JavaScript rewrites URLs in JavaScript present in input and writes output to w.
+//
+// Only URLs that can be found without executing the script are rewritten:
+//
+//   - module specifiers in import and export ... from statements and in import() with a single string literal
+//     are passed to urlRewriter with URLTypeJSImport,
+//   - string literals in new URL("...", import.meta.url) are passed with URLTypeJSImport,
+//   - other string literals with an absolute or root-relative URL ending in a known file extension
+//     are passed with URLTypeJSString.
+//
+// Bare module specifiers (like "react") are not URLs and are not passed to urlRewriter.
+// If the script can't be tokenized, the rest of it is copied verbatim.
+func JavaScript(input *parse.Input, w io.Writer, urlRewriter URLRewriter) error {
+	tokens, rest := tokenizeJavaScript(input)
+	for i, tok := range tokens {
+		if tok.tt != js.StringToken {
+			_, err := w.Write(tok.data)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		urlType, ok := jsStringURLType(tokens, i)
+		if !ok {
+			_, err := w.Write(tok.data)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err := rewriteJSString(tok.data, urlType, w, urlRewriter)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(rest)
+	return err
+}
+
+type jsToken struct {
+	tt   js.TokenType
+	data []byte
+}
+
+// tokenizeJavaScript splits input to tokens.
+// If the input can't be tokenized, rest contains the data that was not tokenized.
+func tokenizeJavaScript(input *parse.Input) (tokens []jsToken, rest []byte) {
+	lexer := js.NewLexer(input)
+	var prev js.TokenType
+	for {
+		startPos := input.Offset()
+		tt, _ := lexer.Next()
+		if (tt == js.DivToken || tt == js.DivEqToken) && jsRegExpAllowed(prev) {
+			tt, _ = lexer.RegExp()
+		}
+		if tt == js.ErrorToken {
+			if !errors.Is(lexer.Err(), io.EOF) {
+				rest = input.Bytes()[startPos:]
+			}
+			return tokens, rest
+		}
+		tokens = append(tokens, jsToken{tt: tt, data: input.Bytes()[startPos:input.Offset()]})
+		if !jsInsignificant(tt) {
+			prev = tt
+		}
+	}
+}
+
+func jsInsignificant(tt js.TokenType) bool {
+	switch tt {
+	case js.WhitespaceToken, js.LineTerminatorToken, js.CommentToken, js.CommentLineTerminatorToken:
+		return true
+	default:
+		return false
+	}
+}
+
+// jsRegExpAllowed returns whether / after a token of type prev starts a regular expression.
+// This is a heuristic, the exact answer depends on the syntactic context.
+func jsRegExpAllowed(prev js.TokenType) bool {
+	switch prev {
+	case js.ErrorToken:
+		// Start of input.
+		return true
+	case js.CloseParenToken, js.CloseBracketToken, js.CloseBraceToken, js.IncrToken, js.DecrToken,
+		js.ThisToken, js.SuperToken, js.TrueToken, js.FalseToken, js.NullToken,
+		js.StringToken, js.TemplateToken, js.TemplateEndToken, js.RegExpToken:
+		return false
+	}
+	switch {
+	case js.IsNumeric(prev), js.IsIdentifier(prev):
+		return false
+	default:
+		return js.IsPunctuator(prev) || js.IsOperator(prev) || js.IsReservedWord(prev)
+	}
+}
+
+// jsStringURLType returns the type of URL in the string token at index i, ok is false if it is not a URL.
+func jsStringURLType(tokens []jsToken, i int) (urlType URLType, ok bool) {
+	prev := jsSignificantTokens(tokens, i, -1, 4)
+	next := jsSignificantTokens(tokens, i, 1, 5)
+	value, err := unescapeJSString(tokens[i].data)
+	if err != nil {
+		return 0, false
+	}
+	switch {
+	case jsTokensMatch(prev, js.FromToken), jsTokensMatch(prev, js.ImportToken) && !jsTokensMatch(next, js.DotToken):
+		// import ... from "x", export ... from "x", import "x"
+		return URLTypeJSImport, isModuleSpecifierURL(value)
+	case jsTokensMatch(prev, js.OpenParenToken, js.ImportToken) &&
+		(jsTokensMatch(next, js.CloseParenToken) || jsTokensMatch(next, js.CommaToken)):
+		// import("x")
+		return URLTypeJSImport, isModuleSpecifierURL(value)
+	case jsTokensMatch(prev, js.OpenParenToken, js.IdentifierToken, js.NewToken) &&
+		string(jsPrevToken(tokens, i, 2).data) == "URL" &&
+		jsTokensMatch(next, js.CommaToken, js.ImportToken, js.DotToken, js.MetaToken, js.DotToken):
+		// new URL("x", import.meta.url)
+		return URLTypeJSImport, value != ""
+	default:
+		return URLTypeJSString, isAssetURL(value)
+	}
+}
+
+// jsSignificantTokens returns types of up to count significant tokens before (dir=-1) or after (dir=1) index i.
+// Tokens before i are returned in reverse order.
+func jsSignificantTokens(tokens []jsToken, i, dir, count int) []js.TokenType {
+	out := make([]js.TokenType, 0, count)
+	for j := i + dir; j >= 0 && j < len(tokens) && len(out) < count; j += dir {
+		if !jsInsignificant(tokens[j].tt) {
+			out = append(out, tokens[j].tt)
+		}
+	}
+	return out
+}
+
+// jsPrevToken returns n-th significant token before index i.
+func jsPrevToken(tokens []jsToken, i, n int) jsToken {
+	for j := i - 1; j >= 0; j-- {
+		if jsInsignificant(tokens[j].tt) {
+			continue
+		}
+		n--
+		if n == 0 {
+			return tokens[j]
+		}
+	}
+	return jsToken{}
+}
+
+// jsTokensMatch returns whether tokens start with expected.
+func jsTokensMatch(tokens []js.TokenType, expected ...js.TokenType) bool {
+	if len(tokens) < len(expected) {
+		return false
+	}
+	for i := range expected {
+		if tokens[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// isModuleSpecifierURL returns whether module specifier is a URL and not a bare specifier.
+// https://html.spec.whatwg.org/multipage/webappapis.html#resolve-a-module-specifier
+func isModuleSpecifierURL(specifier string) bool {
+	return strings.HasPrefix(specifier, "/") || strings.HasPrefix(specifier, "./") ||
+		strings.HasPrefix(specifier, "../") || isAbsoluteHTTPURL(specifier)
+}
+
+func isAbsoluteHTTPURL(s string) bool {
+	lower := strings.ToLower(s)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// jsAssetExtensions are file extensions of string literals considered to be URLs.
+var jsAssetExtensions = map[string]struct{}{
+	".avif": {}, ".css": {}, ".eot": {}, ".gif": {}, ".htm": {}, ".html": {}, ".ico": {}, ".jpeg": {}, ".jpg": {},
+	".js": {}, ".json": {}, ".mjs": {}, ".mp3": {}, ".mp4": {}, ".ogg": {}, ".otf": {}, ".pdf": {}, ".png": {},
+	".svg": {}, ".ttf": {}, ".wasm": {}, ".wav": {}, ".webm": {}, ".webp": {}, ".woff": {}, ".woff2": {},
+}
+
+// isAssetURL returns whether string literal value looks like a URL of a file.
+// Only absolute and root-relative URLs are considered, because relative URLs in strings are usually resolved
+// against the document and not the script.
+func isAssetURL(value string) bool {
+	if strings.ContainsAny(value, " \t\r\n\"'<>`") {
+		return false
+	}
+	if !(strings.HasPrefix(value, "/") && !strings.HasPrefix(value, "//")) && !isAbsoluteHTTPURL(value) {
+		return false
+	}
+	p := value
+	if idx := strings.IndexAny(p, "?#"); idx >= 0 {
+		p = p[:idx]
+	}
+	_, ok := jsAssetExtensions[strings.ToLower(path.Ext(p))]
+	return ok
+}
+
+// rewriteJSString rewrites URL in the string token data.
+func rewriteJSString(data []byte, urlType URLType, w io.Writer, urlRewriter URLRewriter) error {
+	value, err := unescapeJSString(data)
+	if err != nil {
+		return err
+	}
+	newValue, err := urlRewriter(URL{
+		Value: value,
+		Type:  urlType,
+	})
+	switch {
+	case errors.Is(err, ErrNotModified):
+		_, err = w.Write(data)
+		return err
+	case err != nil:
+		return err
+	}
+	_, err = io.WriteString(w, escapeJSString(newValue, rune(data[0])))
+	return err
+}
+
+// unescapeJSString returns the value of string literal token.
+func unescapeJSString(data []byte) (string, error) {
+	if len(data) < 2 || (data[0] != '"' && data[0] != '\'') || data[len(data)-1] != data[0] {
+		return "", fmt.Errorf("invalid string literal %q", data)
+	}
+	s := string(data[1 : len(data)-1])
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("invalid escape in string literal %q", data)
+		}
+		switch c := s[i]; c {
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'v':
+			sb.WriteByte('\v')
+		case '0':
+			sb.WriteByte(0)
+		case '\r':
+			// Line continuation.
+			if i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+		case '\n':
+			// Line continuation.
+		case 'x':
+			if i+2 >= len(s) {
+				return "", fmt.Errorf("invalid escape in string literal %q", data)
+			}
+			r, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteRune(rune(r))
+			i += 2
+		case 'u':
+			hex := ""
+			if i+1 < len(s) && s[i+1] == '{' {
+				end := strings.IndexByte(s[i:], '}')
+				if end < 0 {
+					return "", fmt.Errorf("invalid escape in string literal %q", data)
+				}
+				hex = s[i+2 : i+end]
+				i += end
+			} else {
+				if i+4 >= len(s) {
+					return "", fmt.Errorf("invalid escape in string literal %q", data)
+				}
+				hex = s[i+1 : i+5]
+				i += 4
+			}
+			r, err := strconv.ParseUint(hex, 16, 32)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteRune(rune(r))
+		default:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			sb.WriteRune(r)
+			i += size - 1
+		}
+	}
+	return sb.String(), nil
+}
+
+// escapeJSString returns string literal with value.
+// < is always escaped so that the string can't end an inline script element.
+func escapeJSString(value string, quote rune) string {
+	var sb strings.Builder
+	sb.Grow(len(value) + 2)
+	sb.WriteRune(quote)
+	for _, r := range value {
+		switch r {
+		case '\\', quote:
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '<':
+			sb.WriteString(`\x3C`)
+		case '\u2028':
+			sb.WriteString(`\u2028`)
+		case '\u2029':
+			sb.WriteString(`\u2029`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteRune(quote)
+	return sb.String()
+}
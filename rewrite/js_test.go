@@ -0,0 +1,124 @@
+package rewrite
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tdewolff/parse/v2"
+)
+
+func TestJavaScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output string
+		urls   []URL
+	}{
+		{
+			name: "imports",
+			input: "import a from './a.js';\nimport {b} from \"../b.mjs\"\nimport './c.js'\n" +
+				"export * from '/d.js';\nimport React from 'react';\nconst e = await import('./e.js');\n" +
+				"const f = import('./f' + name);\nimport.meta.url;",
+			output: "import a from '/new/./a.js';\nimport {b} from \"/new/../b.mjs\"\nimport '/new/./c.js'\n" +
+				"export * from '/new//d.js';\nimport React from 'react';\nconst e = await import('/new/./e.js');\n" +
+				"const f = import('./f' + name);\nimport.meta.url;",
+			urls: []URL{
+				{Value: "./a.js", Type: URLTypeJSImport},
+				{Value: "../b.mjs", Type: URLTypeJSImport},
+				{Value: "./c.js", Type: URLTypeJSImport},
+				{Value: "/d.js", Type: URLTypeJSImport},
+				{Value: "./e.js", Type: URLTypeJSImport},
+			},
+		},
+		{
+			name:   "new URL",
+			input:  "const worker = new Worker(new URL(\"./worker.js\", import.meta.url));",
+			output: "const worker = new Worker(new URL(\"/new/./worker.js\", import.meta.url));",
+			urls: []URL{
+				{Value: "./worker.js", Type: URLTypeJSImport},
+			},
+		},
+		{
+			name: "string literals",
+			input: "img.src = '/img/logo.png?v=2'; x = 'https://example.com/a.css'; y = 'logo.png';" +
+				" z = '/api/users'; w = 'hello world.png';",
+			output: "img.src = '/new//img/logo.png?v=2'; x = '/new/https://example.com/a.css'; y = 'logo.png';" +
+				" z = '/api/users'; w = 'hello world.png';",
+			urls: []URL{
+				{Value: "/img/logo.png?v=2", Type: URLTypeJSString},
+				{Value: "https://example.com/a.css", Type: URLTypeJSString},
+			},
+		},
+		{
+			name:   "regexp",
+			input:  "var re = /'\\/a.png'/; var x = a / 2 / '/b.png'.length;",
+			output: "var re = /'\\/a.png'/; var x = a / 2 / '/new//b.png'.length;",
+			urls: []URL{
+				{Value: "/b.png", Type: URLTypeJSString},
+			},
+		},
+		{
+			name:   "escapes",
+			input:  `x = "\/a.png"`,
+			output: `x = "/new//a.png"`,
+			urls: []URL{
+				{Value: "/a.png", Type: URLTypeJSString},
+			},
+		},
+		{
+			name:   "untokenizable rest is copied",
+			input:  "x = '/a.png'; #\"/b.png\"",
+			output: "x = '/new//a.png'; #\"/b.png\"",
+			urls: []URL{
+				{Value: "/a.png", Type: URLTypeJSString},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var urls []URL
+			var buf bytes.Buffer
+			err := JavaScript(parse.NewInputString(test.input), &buf, func(url URL) (string, error) {
+				urls = append(urls, url)
+				return "/new/" + url.Value, nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, test.output, buf.String())
+			assert.Equal(t, test.urls, urls)
+		})
+	}
+}
+
+func TestEscapeJSString(t *testing.T) {
+	assert.Equal(t, `'a\'b"\\\x3C/script>'`, escapeJSString(`a'b"\</script>`, '\''))
+	assert.Equal(t, `"a'b\""`, escapeJSString(`a'b"`, '"'))
+}
+
+func TestHTML5_Script(t *testing.T) {
+	input := `<base href="http://example.com/"><script src="a.js"></script>` +
+		`<script type="module">import "./b.js"; const s = "</p>";</script>` +
+		`<script type="text/template"><img src="/c.png"></script><script/>`
+	output := `<base href="http://example.com/"><script src="/new/a.js"></script>` +
+		`<script type="module">import "/new/./b.js"; const s = "</p>";</script>` +
+		`<script type="text/template"><img src="/c.png"></script><script/>`
+	var urls []URL
+	var buf bytes.Buffer
+	err := HTML5(parse.NewInputString(input), &buf, func(url URL) (string, error) {
+		urls = append(urls, url)
+		if url.Type == URLTypeBase {
+			return "", ErrNotModified
+		}
+		return "/new/" + url.Value, nil
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, output, buf.String())
+	assert.Equal(t, []URL{
+		{Value: "http://example.com/", Type: URLTypeBase, Tag: "base", Attribute: "href"},
+		{Value: "a.js", Base: "http://example.com/", NewBase: "http://example.com/", Tag: "script",
+			Attribute: "src"},
+		{Value: "./b.js", Base: "http://example.com/", NewBase: "http://example.com/", Type: URLTypeJSImport,
+			Tag: "script"},
+	}, urls)
+}
@@ -0,0 +1,146 @@
+package rewrite
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/json"
+)
+
+// DefaultJSONKeys are keys of JSON-LD and web app manifest properties with URL values.
+//
+// https://schema.org/URL
+// https://www.w3.org/TR/appmanifest/
+var DefaultJSONKeys = []string{
+	// JSON-LD
+	"@id", "url", "logo", "image", "sameAs", "contentUrl", "thumbnailUrl", "embedUrl", "mainEntityOfPage",
+	// Web app manifest
+	"start_url", "scope", "src", "action",
+}
+
+// JSON rewrites URLs in JSON document present in input and writes output to w.
+//
+// String values of properties with one of keys, directly or in arrays, are passed to urlRewriter as URLTypeJSON.
+// If keys is nil, DefaultJSONKeys are used.
+// Formatting of the document is preserved. If the document is not valid JSON, the rest of it is copied verbatim.
+func JSON(input *parse.Input, w io.Writer, urlRewriter URLRewriter, keys []string) error {
+	return rewriteJSON(input, w, jsonKeysRewriter(keys, func(value string) (string, error) {
+		return urlRewriter(URL{Value: value, Type: URLTypeJSON})
+	}))
+}
+
+// jsonKeysRewriter returns jsonStringRewriter that rewrites string values of properties with one of keys
+// using rewriteURL.
+func jsonKeysRewriter(keys []string, rewriteURL func(value string) (string, error)) jsonStringRewriter {
+	if keys == nil {
+		keys = DefaultJSONKeys
+	}
+	keySet := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		keySet[key] = struct{}{}
+	}
+	return func(path []string, key bool, value string) (string, error) {
+		if key || len(path) == 0 {
+			return "", ErrNotModified
+		}
+		if _, ok := keySet[path[len(path)-1]]; !ok {
+			return "", ErrNotModified
+		}
+		// Blank node identifiers in JSON-LD are not URLs.
+		if value == "" || strings.HasPrefix(value, "_:") {
+			return "", ErrNotModified
+		}
+		return rewriteURL(value)
+	}
+}
+
+// jsonStringRewriter returns the new value of a string in a JSON document or ErrNotModified.
+//
+// path contains the keys of objects enclosing the string, arrays are not part of the path.
+// key is true if the string is an object key, path does not include the key itself in that case.
+type jsonStringRewriter func(path []string, key bool, value string) (string, error)
+
+// rewriteJSON rewrites strings in the JSON document present in input and writes output to w.
+//
+// Formatting of the document is preserved, only the rewritten strings are re-encoded.
+// If the document is not valid JSON, the rest of it is copied verbatim.
+func rewriteJSON(input *parse.Input, w io.Writer, rewriter jsonStringRewriter) error {
+	parser := json.NewParser(input)
+	// frames contains one entry per enclosing object or array, object entries hold the current key.
+	type frame struct {
+		object bool
+		key    string
+	}
+	var frames []frame
+	path := func(n int) []string {
+		var p []string
+		for _, f := range frames[:n] {
+			if f.object {
+				p = append(p, f.key)
+			}
+		}
+		return p
+	}
+	for {
+		startPos := input.Offset()
+		state := parser.State()
+		gt, data := parser.Next()
+		raw := input.Bytes()[startPos:input.Offset()]
+		switch gt {
+		case json.ErrorGrammar:
+			_, err := w.Write(input.Bytes()[startPos:])
+			return err
+		case json.StartObjectGrammar, json.StartArrayGrammar:
+			frames = append(frames, frame{object: gt == json.StartObjectGrammar})
+		case json.EndObjectGrammar, json.EndArrayGrammar:
+			if len(frames) > 0 {
+				frames = frames[:len(frames)-1]
+			}
+		case json.StringGrammar:
+			var value string
+			err := stdjson.Unmarshal(data, &value)
+			if err != nil {
+				_, err = w.Write(input.Bytes()[startPos:])
+				return err
+			}
+			var newValue string
+			if state == json.ObjectKeyState && len(frames) > 0 {
+				frames[len(frames)-1].key = value
+				newValue, err = rewriter(path(len(frames)-1), true, value)
+			} else {
+				newValue, err = rewriter(path(len(frames)), false, value)
+			}
+			switch {
+			case errors.Is(err, ErrNotModified):
+			case err != nil:
+				return err
+			default:
+				err = writeJSONString(w, raw, data, newValue)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		_, err := w.Write(raw)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeJSONString writes raw token data with the string token replaced by value.
+// raw may contain whitespace and comma before the string and colon after an object key.
+func writeJSONString(w io.Writer, raw, data []byte, value string) error {
+	start := bytes.IndexByte(raw, '"')
+	// json.Marshal escapes <, > and & so that the output is safe to embed in HTML script element.
+	encoded, err := stdjson.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return multiWrite(w, raw[:start], encoded, raw[start+len(data):])
+}
@@ -0,0 +1,104 @@
+package rewrite
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tdewolff/parse/v2"
+)
+
+func TestRewriteJSON(t *testing.T) {
+	input := `["/a", "b"]`
+	var buf bytes.Buffer
+	err := rewriteJSON(parse.NewInputString(input), &buf, func(path []string, key bool, value string) (string, error) {
+		if value != "/a" {
+			return "", ErrNotModified
+		}
+		return "</script>", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `["\u003c/script\u003e", "b"]`, buf.String())
+}
+
+func TestJSON_Manifest(t *testing.T) {
+	input := `{
+  "name": "App",
+  "start_url": "/?source=pwa",
+  "scope": "/",
+  "icons": [
+    {"src": "/icons/192.png", "sizes": "192x192"},
+    {"src": "https://cdn.example.com/512.png", "sizes": "512x512"}
+  ],
+  "shortcuts": [{"name": "Open", "url": "/open"}],
+  "description": "/not-a-url-key"
+}`
+	output := `{
+  "name": "App",
+  "start_url": "/new/?source=pwa",
+  "scope": "/new/",
+  "icons": [
+    {"src": "/new/icons/192.png", "sizes": "192x192"},
+    {"src": "/new/https://cdn.example.com/512.png", "sizes": "512x512"}
+  ],
+  "shortcuts": [{"name": "Open", "url": "/new/open"}],
+  "description": "/not-a-url-key"
+}`
+	var urls []URL
+	var buf bytes.Buffer
+	err := Document("application/manifest+json", map[string]string{}, parse.NewInputString(input), &buf,
+		func(url URL) (string, error) {
+			urls = append(urls, url)
+			return "/new/" + strings.TrimPrefix(url.Value, "/"), nil
+		}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, output, buf.String())
+	assert.Equal(t, []URL{
+		{Value: "/?source=pwa", Type: URLTypeJSON},
+		{Value: "/", Type: URLTypeJSON},
+		{Value: "/icons/192.png", Type: URLTypeJSON},
+		{Value: "https://cdn.example.com/512.png", Type: URLTypeJSON},
+		{Value: "/open", Type: URLTypeJSON},
+	}, urls)
+}
+
+func TestJSON_Keys(t *testing.T) {
+	input := `{"url": "/a", "photo": {"href": "/b"}, "href": ["/c", {"url": "/d"}]}`
+	var urls []string
+	var buf bytes.Buffer
+	err := JSON(parse.NewInputString(input), &buf, func(url URL) (string, error) {
+		urls = append(urls, url.Value)
+		return "", ErrNotModified
+	}, []string{"href"})
+	require.NoError(t, err)
+	assert.Equal(t, input, buf.String())
+	assert.Equal(t, []string{"/b", "/c"}, urls)
+}
+
+func TestHTML5_JSONLD(t *testing.T) {
+	input := `<base href="http://example.com/"><script type="application/ld+json">{"@context": "https://schema.org",
+"@graph": [{"@type": "Organization", "@id": "https://example.com/#org", "logo": {"url": "/logo.png"},
+"sameAs": ["https://twitter.com/example"]}, {"@id": "_:b0", "name": "http://example.com/name"}]}</script>`
+	output := `<base href="http://example.com/"><script type="application/ld+json">{"@context": "https://schema.org",
+"@graph": [{"@type": "Organization", "@id": "/new/https://example.com/#org", "logo": {"url": "/new//logo.png"},
+"sameAs": ["/new/https://twitter.com/example"]}, {"@id": "_:b0", "name": "http://example.com/name"}]}</script>`
+	var urls []URL
+	var buf bytes.Buffer
+	err := HTML5(parse.NewInputString(input), &buf, func(url URL) (string, error) {
+		if url.Type == URLTypeBase {
+			return "", ErrNotModified
+		}
+		urls = append(urls, url)
+		return "/new/" + url.Value, nil
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, output, buf.String())
+	base := "http://example.com/"
+	assert.Equal(t, []URL{
+		{Value: "https://example.com/#org", Base: base, NewBase: base, Type: URLTypeJSON, Tag: "script"},
+		{Value: "/logo.png", Base: base, NewBase: base, Type: URLTypeJSON, Tag: "script"},
+		{Value: "https://twitter.com/example", Base: base, NewBase: base, Type: URLTypeJSON, Tag: "script"},
+	}, urls)
+}
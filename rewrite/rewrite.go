@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"strings"
 
 	"github.com/tdewolff/parse/v2"
 )
@@ -27,6 +26,18 @@ type URL struct {
 	NewBase string
 	// Type of the URL.
 	Type URLType
+	// Tag is the name of the HTML element containing the URL.
+	// Empty if the URL is not in HTML.
+	Tag string
+	// Attribute is the name of the HTML attribute containing the URL.
+	// Empty if the URL is not in an HTML attribute.
+	Attribute string
+}
+
+// IsFetchable returns whether the URL refers to a resource.
+// Namespace names in CSS @namespace rules are identifiers, they are not fetched and links to them are not rewritten.
+func (u URL) IsFetchable() bool {
+	return u.Type != URLTypeCSSNamespace
 }
 
 type URLType uint8
@@ -36,29 +47,152 @@ const (
 	URLTypeBase
 	URLTypeOpenGraph
 	URLTypeCSS
+	// URLTypeCSSNamespace is a namespace name in CSS @namespace rule.
+	// It identifies a namespace and is not meant to be fetched, see URL.IsFetchable.
+	URLTypeCSSNamespace
+	// URLTypeJSImport is a module specifier or a URL resolved against import.meta.url in JavaScript,
+	// a URL in an import map or a module preloaded with <link rel="modulepreload">.
+	URLTypeJSImport
+	// URLTypeJSString is a string literal in JavaScript that looks like a URL.
+	// The string might be used for other purposes than a URL, so it is not always safe to rewrite it.
+	URLTypeJSString
+	// URLTypeHTTPLink is a URI reference in the Link response header.
+	URLTypeHTTPLink
+	// URLTypeHTTPRefresh is a URL in the Refresh response header.
+	URLTypeHTTPRefresh
+	// URLTypeHTTPContentLocation is the value of the Content-Location response header.
+	URLTypeHTTPContentLocation
+	// URLTypeHTTPLocation is the value of the Location response header.
+	URLTypeHTTPLocation
+	// URLTypeJSON is a value of a URL property in JSON-LD or web app manifest.
+	URLTypeJSON
 )
 
+var urlTypeNames = [...]string{
+	URLTypeUnknown:             "unknown",
+	URLTypeBase:                "base",
+	URLTypeOpenGraph:           "opengraph",
+	URLTypeCSS:                 "css",
+	URLTypeCSSNamespace:        "css-namespace",
+	URLTypeJSImport:            "js-import",
+	URLTypeJSString:            "js-string",
+	URLTypeHTTPLink:            "http-link",
+	URLTypeHTTPRefresh:         "http-refresh",
+	URLTypeHTTPContentLocation: "http-content-location",
+	URLTypeHTTPLocation:        "http-location",
+	URLTypeJSON:                "json",
+}
+
+func (t URLType) String() string {
+	if int(t) < len(urlTypeNames) {
+		return urlTypeNames[t]
+	}
+	return fmt.Sprintf("URLType(%d)", t)
+}
+
+// Options configure rewriting of documents.
+// nil *Options is the same as zero Options.
+type Options struct {
+	// Attributes are HTML attributes containing URLs in addition to the built-in ones.
+	// Later attributes override earlier ones for the same tag and name.
+	Attributes []Attribute
+	// JSONKeys are keys of properties with URL values in JSON-LD and web app manifests.
+	// nil means DefaultJSONKeys.
+	JSONKeys []string
+}
+
 // IsSupportedMediaType returns whether the given media type (as returned from mime.ParseMediaType) is supported.
 func IsSupportedMediaType(mediaType string, params map[string]string) bool {
-	if mediaType != "text/html" && mediaType != "text/css" {
-		return false
+	switch mediaType {
+	case "text/html", "text/css", "image/svg+xml":
+	default:
+		if !isJavaScriptMediaType(mediaType) && !isFeedMediaType(mediaType) && !isJSONMediaType(mediaType) {
+			return false
+		}
 	}
-	return params["charset"] == "" || strings.EqualFold(params["charset"], "utf-8")
+	return isSupportedCharset(params["charset"])
 }
 
 // Document rewrites whole document by given MIME media type.
+//
+// Documents in encodings other than UTF-8 are written in their original encoding.
 func Document(mediaType string, mediaParams map[string]string, input *parse.Input, w io.Writer,
-	urlRewriter URLRewriter) error {
+	urlRewriter URLRewriter, opts *Options) error {
 	if !IsSupportedMediaType(mediaType, mediaParams) {
 		return fmt.Errorf("unsupported media type: %s %v", mediaType, mediaParams)
 	}
 
+	var rewriteUTF8 func(input *parse.Input, w io.Writer) error
 	switch mediaType {
 	case "text/html":
-		return HTML5(input, w, urlRewriter)
+		rewriteUTF8 = func(input *parse.Input, w io.Writer) error {
+			return HTML5(input, w, urlRewriter, opts)
+		}
 	case "text/css":
-		return CSS(input, w, urlRewriter, false)
+		rewriteUTF8 = func(input *parse.Input, w io.Writer) error {
+			return CSS(input, w, urlRewriter, false)
+		}
+	case "image/svg+xml":
+		rewriteUTF8 = func(input *parse.Input, w io.Writer) error {
+			return SVG(input, w, urlRewriter)
+		}
 	default:
-		return fmt.Errorf("unsupported media type: %s %v", mediaType, mediaParams)
+		switch {
+		case isJavaScriptMediaType(mediaType):
+			rewriteUTF8 = func(input *parse.Input, w io.Writer) error {
+				return JavaScript(input, w, urlRewriter)
+			}
+		case isFeedMediaType(mediaType):
+			rewriteUTF8 = func(input *parse.Input, w io.Writer) error {
+				return Feed(input, w, urlRewriter)
+			}
+		case isJSONMediaType(mediaType):
+			rewriteUTF8 = func(input *parse.Input, w io.Writer) error {
+				var keys []string
+				if opts != nil {
+					keys = opts.JSONKeys
+				}
+				return JSON(input, w, urlRewriter, keys)
+			}
+		default:
+			return fmt.Errorf("unsupported media type: %s %v", mediaType, mediaParams)
+		}
+	}
+
+	enc, err := documentEncoding(mediaType, mediaParams, input.Bytes())
+	if err != nil {
+		return err
+	}
+	if enc == nil {
+		return rewriteUTF8(input, w)
+	}
+	escapeHTML := mediaType == "text/html" || mediaType == "image/svg+xml" || isFeedMediaType(mediaType)
+	return rewriteEncoded(enc, input.Bytes(), w, escapeHTML, rewriteUTF8)
+}
+
+// isFeedMediaType returns whether the media type is an XML type used for RSS and Atom feeds and sitemaps.
+func isFeedMediaType(mediaType string) bool {
+	switch mediaType {
+	case "application/rss+xml", "application/atom+xml", "text/xml", "application/xml":
+		return true
+	default:
+		return false
+	}
+}
+
+// isJSONMediaType returns whether the media type is a JSON type rewritten by JSON.
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/manifest+json" || mediaType == "application/ld+json"
+}
+
+// isJavaScriptMediaType returns whether the media type is a JavaScript MIME type.
+// https://mimesniff.spec.whatwg.org/#javascript-mime-type
+func isJavaScriptMediaType(mediaType string) bool {
+	switch mediaType {
+	case "text/javascript", "application/javascript", "application/x-javascript", "application/ecmascript",
+		"text/ecmascript", "text/x-javascript":
+		return true
+	default:
+		return false
 	}
 }
@@ -0,0 +1,83 @@
+package rewrite
+
+import (
+	"errors"
+	"strings"
+)
+
+// srcSetAttribute rewrites URLs of image candidates in srcset attribute.
+// Everything except the rewritten URLs, including whitespace, is kept as it is.
+func srcSetAttribute(lc *html5Rewriter, attrValue string) (string, error) {
+	var sb strings.Builder
+	anyModified := false
+	last := 0
+	for _, span := range srcSetURLs(attrValue) {
+		value := attrValue[span[0]:span[1]]
+		rewritten, err := lc.urlRewriter(lc.newURL(value, URLTypeUnknown))
+		switch {
+		case errors.Is(err, ErrNotModified):
+			continue
+		case err != nil:
+			return "", err
+		}
+		sb.WriteString(attrValue[last:span[0]])
+		sb.WriteString(rewritten)
+		last = span[1]
+		anyModified = true
+	}
+	if !anyModified {
+		return "", ErrNotModified
+	}
+	sb.WriteString(attrValue[last:])
+	return sb.String(), nil
+}
+
+// srcSetURLs returns start and end offsets of URLs of image candidates in srcset attribute value s.
+// https://html.spec.whatwg.org/multipage/images.html#parsing-a-srcset-attribute
+func srcSetURLs(s string) [][2]int {
+	var spans [][2]int
+	pos := 0
+	for {
+		// Skip separators between candidates.
+		for pos < len(s) && (isHTMLSpace(s[pos]) || s[pos] == ',') {
+			pos++
+		}
+		if pos >= len(s) {
+			return spans
+		}
+		start := pos
+		for pos < len(s) && !isHTMLSpace(s[pos]) {
+			pos++
+		}
+		end := pos
+		if s[end-1] == ',' {
+			// URL followed directly by a comma has no descriptors.
+			for end > start && s[end-1] == ',' {
+				end--
+			}
+			if end > start {
+				spans = append(spans, [2]int{start, end})
+			}
+			continue
+		}
+		spans = append(spans, [2]int{start, end})
+		// Skip descriptors, commas inside parentheses don't end the candidate.
+		inParens := false
+		for pos < len(s) {
+			c := s[pos]
+			pos++
+			if c == '(' {
+				inParens = true
+			} else if c == ')' {
+				inParens = false
+			} else if c == ',' && !inParens {
+				break
+			}
+		}
+	}
+}
+
+// isHTMLSpace returns whether c is ASCII whitespace as defined by HTML.
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
@@ -0,0 +1,60 @@
+package rewrite
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tdewolff/parse/v2"
+)
+
+func TestSrcSetURLs(t *testing.T) {
+	tests := []struct {
+		input string
+		urls  []string
+	}{
+		{input: "", urls: nil},
+		{input: " , ,", urls: nil},
+		{input: "a.png", urls: []string{"a.png"}},
+		{input: "a.png 1x, b.png 2x", urls: []string{"a.png", "b.png"}},
+		{input: "a.png,b.png 2x", urls: []string{"a.png,b.png"}},
+		{input: "a.png,, b.png", urls: []string{"a.png", "b.png"}},
+		{input: "\n  /img/w_300,h_200/a.jpg 300w,\n  /img/w_600,h_400/a.jpg 600w\n",
+			urls: []string{"/img/w_300,h_200/a.jpg", "/img/w_600,h_400/a.jpg"}},
+		{input: "a.png 100w (x, y), b.png", urls: []string{"a.png", "b.png"}},
+		{input: "a.png 1x ,b.png", urls: []string{"a.png", "b.png"}},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var urls []string
+			for _, span := range srcSetURLs(test.input) {
+				urls = append(urls, test.input[span[0]:span[1]])
+			}
+			assert.Equal(t, test.urls, urls)
+		})
+	}
+}
+
+func TestHTML5_SrcSet(t *testing.T) {
+	input := `<img srcset="/img/w_300,h_200/a.jpg 300w,
+	/img/w_600,h_400/a.jpg   600w" sizes="(max-width: 600px) 300px, 600px">` +
+		`<img srcset="keep.png 1x,  b.png 2x">` +
+		`<link rel="preload" as="image" imagesrcset="c.png 1x,d.png 2x" href="c.png">` +
+		`<link rel="icon" imagesrcset="e.png 1x">`
+	output := `<img srcset="/new/img/w_300,h_200/a.jpg 300w,
+	/new/img/w_600,h_400/a.jpg   600w" sizes="(max-width: 600px) 300px, 600px">` +
+		`<img srcset="keep.png 1x,  /new/b.png 2x">` +
+		`<link rel="preload" as="image" imagesrcset="/new/c.png 1x,/new/d.png 2x" href="/new/c.png">` +
+		`<link rel="icon" imagesrcset="e.png 1x">`
+	var buf bytes.Buffer
+	err := HTML5(parse.NewInputString(input), &buf, func(url URL) (string, error) {
+		if url.Value == "keep.png" {
+			return "", ErrNotModified
+		}
+		return "/new/" + strings.TrimPrefix(url.Value, "/"), nil
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, output, buf.String())
+}
@@ -0,0 +1,84 @@
+package rewrite
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/tdewolff/parse/v2"
+)
+
+// SVG rewrites SVG document present in input, replace links with the result of urlRewriter and write output to w.
+//
+// URLs in href and xlink:href attributes of all elements, in style attributes and in <style> elements are rewritten.
+func SVG(input *parse.Input, w io.Writer, urlRewriter URLRewriter) error {
+	return rewriteXML(input, w, &svgPolicy{urlRewriter: urlRewriter})
+}
+
+type svgPolicy struct {
+	urlRewriter URLRewriter
+	// currentTag is the local name of the last start tag.
+	currentTag string
+	// inStyle is true inside <style> element.
+	inStyle bool
+}
+
+func (p *svgPolicy) startTag(name string) {
+	p.currentTag = name
+	p.inStyle = false
+}
+
+func (p *svgPolicy) startTagClose() {
+	p.inStyle = p.currentTag == "style"
+}
+
+func (p *svgPolicy) endTag() {
+	p.inStyle = false
+}
+
+// tagURLRewriter returns urlRewriter that fills in the current tag and the attribute.
+func (p *svgPolicy) tagURLRewriter(attrName string) URLRewriter {
+	tag := p.currentTag
+	return func(u URL) (string, error) {
+		u.Tag = tag
+		u.Attribute = attrName
+		return p.urlRewriter(u)
+	}
+}
+
+func (p *svgPolicy) attribute(name, value string) (string, error) {
+	switch {
+	case localName([]byte(name)) == "href":
+		return p.urlRewriter(URL{
+			Value:     value,
+			Type:      URLTypeUnknown,
+			Tag:       p.currentTag,
+			Attribute: name,
+		})
+	case name == "style":
+		var sb strings.Builder
+		err := CSS(parse.NewInputString(value), &sb, p.tagURLRewriter(name), true)
+		if err != nil {
+			return "", err
+		}
+		if sb.String() == value {
+			return "", ErrNotModified
+		}
+		return sb.String(), nil
+	default:
+		return "", ErrNotModified
+	}
+}
+
+func (p *svgPolicy) rewritesText() bool {
+	return p.inStyle
+}
+
+func (p *svgPolicy) text(value string) (string, error) {
+	var buf bytes.Buffer
+	err := CSS(parse.NewInputString(value), &buf, p.tagURLRewriter(""), false)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
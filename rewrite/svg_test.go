@@ -0,0 +1,86 @@
+package rewrite
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tdewolff/parse/v2"
+)
+
+func TestSVG(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output string
+		urls   []URL
+	}{
+		{
+			name: "verbatim",
+			input: "<?xml version=\"1.0\"?>\n<!-- comment -->\n<svg xmlns=\"http://www.w3.org/2000/svg\"\n" +
+				"  viewBox=\"0 0 10\tn10\" ><rect  width='10'\n/></svg>",
+			output: "<?xml version=\"1.0\"?>\n<!-- comment -->\n<svg xmlns=\"http://www.w3.org/2000/svg\"\n" +
+				"  viewBox=\"0 0 10\tn10\" ><rect  width='10'\n/></svg>",
+		},
+		{
+			name: "href",
+			input: `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="sprite.svg#icon"/>` +
+				`<image href='a.png?x=1&amp;y=2' /><a href="#local">x</a></svg>`,
+			output: `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="/new/sprite.svg#icon"/>` +
+				`<image href='/new/a.png?x=1&amp;y=2' /><a href="/new/#local">x</a></svg>`,
+			urls: []URL{
+				{Value: "sprite.svg#icon", Tag: "use", Attribute: "xlink:href"},
+				{Value: "a.png?x=1&y=2", Tag: "image", Attribute: "href"},
+				{Value: "#local", Tag: "a", Attribute: "href"},
+			},
+		},
+		{
+			name: "style",
+			input: `<svg><style>rect { fill: url(a.png) } a &gt; b {}</style>` +
+				`<style><![CDATA[ @import "b.css"; ]]></style><rect style="background: url('c.png')"/></svg>`,
+			output: `<svg><style>rect { fill: url("/new/a.png") } a &gt; b {}</style>` +
+				`<style><![CDATA[ @import "/new/b.css"; ]]></style><rect style="background: url('/new/c.png')"/></svg>`,
+			urls: []URL{
+				{Value: "a.png", Type: URLTypeCSS, Tag: "style"},
+				{Value: "b.css", Type: URLTypeCSS, Tag: "style"},
+				{Value: "c.png", Type: URLTypeCSS, Tag: "rect", Attribute: "style"},
+			},
+		},
+		{
+			name:   "whitespace in attributes",
+			input:  "<svg><rect style=\"fill: url(a.png);\n\tstroke: red\"/><a href=\"\n b.svg\t\"/></svg>",
+			output: "<svg><rect style=\"fill: url(&#34;/new/a.png&#34;);\n\tstroke: red\"/><a href=\"/new/\n b.svg\t\"/></svg>",
+			urls: []URL{
+				{Value: "a.png", Type: URLTypeCSS, Tag: "rect", Attribute: "style"},
+				{Value: "\n b.svg\t", Tag: "a", Attribute: "href"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var urls []URL
+			var buf bytes.Buffer
+			err := SVG(parse.NewInputString(test.input), &buf, func(url URL) (string, error) {
+				urls = append(urls, url)
+				return "/new/" + url.Value, nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, test.output, buf.String())
+			assert.Equal(t, test.urls, urls)
+		})
+	}
+}
+
+func TestDocument_SVGEncoding(t *testing.T) {
+	input := "<?xml version=\"1.0\" encoding=\"ISO-8859-2\"?><svg><image href=\"\xB9.png\"/></svg>"
+	var buf bytes.Buffer
+	err := Document("image/svg+xml", map[string]string{}, parse.NewInputString(input), &buf,
+		func(url URL) (string, error) {
+			assert.Equal(t, "š.png", url.Value)
+			return "/new/" + url.Value, nil
+		}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "<?xml version=\"1.0\" encoding=\"ISO-8859-2\"?><svg><image href=\"/new/\xB9.png\"/></svg>",
+		buf.String())
+}
@@ -0,0 +1,180 @@
+package rewrite
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	stdhtml "html"
+	"io"
+	"strings"
+
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/xml"
+)
+
+// xmlPolicy decides which attributes and text of an XML document are rewritten by rewriteXML.
+type xmlPolicy interface {
+	// startTag is called for each start tag with its local name.
+	startTag(name string)
+	// startTagClose is called at the end of a start tag, after its attributes.
+	startTagClose()
+	// endTag is called for each end tag.
+	endTag()
+	// attribute returns the new value of attribute of the current element.
+	// name is the qualified name of the attribute and value is the unescaped value.
+	// ErrNotModified keeps the attribute as it is.
+	attribute(name, value string) (string, error)
+	// rewritesText returns whether text at the current position is passed to text.
+	rewritesText() bool
+	// text returns the new text, value is the unescaped text or content of a CDATA section.
+	// ErrNotModified keeps the text as it is.
+	text(value string) (string, error)
+}
+
+// rewriteXML copies XML document present in input to w, replacing attribute values and text as decided by policy.
+// Everything else is copied verbatim.
+func rewriteXML(input *parse.Input, w io.Writer, policy xmlPolicy) error {
+	lc := xmlRewriter{
+		input: input,
+		// The lexer modifies whitespace in attribute values in place, keep the original data to copy.
+		original: append([]byte(nil), input.Bytes()...),
+		lexer:    xml.NewLexer(input),
+		w:        w,
+		policy:   policy,
+	}
+	for {
+		tt, _ := lc.next()
+		var err error
+		switch tt {
+		case xml.ErrorToken:
+			return ignoreEOF(lc.lexer.Err())
+		case xml.StartTagToken:
+			policy.startTag(localName(lc.lexer.Text()))
+			err = lc.copy()
+		case xml.StartTagCloseToken:
+			policy.startTagClose()
+			err = lc.copy()
+		case xml.AttributeToken:
+			err = lc.processAttribute()
+		case xml.TextToken:
+			if !policy.rewritesText() {
+				err = lc.copy()
+				break
+			}
+			err = lc.processText()
+		case xml.CDATAToken:
+			if !policy.rewritesText() {
+				err = lc.copy()
+				break
+			}
+			err = lc.processCDATA()
+		case xml.EndTagToken:
+			policy.endTag()
+			err = lc.copy()
+		default:
+			err = lc.copy()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type xmlRewriter struct {
+	input            *parse.Input
+	original         []byte
+	lexer            *xml.Lexer
+	w                io.Writer
+	startPos, endPos int
+	policy           xmlPolicy
+}
+
+func (lc *xmlRewriter) next() (xml.TokenType, []byte) {
+	lc.startPos = lc.input.Offset()
+	tt, data := lc.lexer.Next()
+	lc.endPos = lc.input.Offset()
+	return tt, data
+}
+
+func (lc *xmlRewriter) rawData() []byte {
+	return lc.original[lc.startPos:lc.endPos]
+}
+
+func (lc *xmlRewriter) copy() error {
+	_, err := lc.w.Write(lc.rawData())
+	return err
+}
+
+// localName returns name without namespace prefix.
+func localName(name []byte) string {
+	if idx := bytes.IndexByte(name, ':'); idx >= 0 {
+		name = name[idx+1:]
+	}
+	return string(name)
+}
+
+func (lc *xmlRewriter) processAttribute() error {
+	attrName := string(lc.lexer.Text())
+	raw := lc.rawData()
+	// The value is at the end of the token. AttrVal has the same length, but its whitespace is normalized.
+	attrValue := raw[len(raw)-len(lc.lexer.AttrVal()):]
+	if len(attrValue) < 2 || (attrValue[0] != '"' && attrValue[0] != '\'') || attrValue[len(attrValue)-1] != attrValue[0] {
+		// XML attribute values are always quoted.
+		return lc.copy()
+	}
+	quote := attrValue[0]
+	value := stdhtml.UnescapeString(string(attrValue[1 : len(attrValue)-1]))
+	newValue, err := lc.policy.attribute(attrName, value)
+	switch {
+	case errors.Is(err, ErrNotModified):
+		return lc.copy()
+	case err != nil:
+		return err
+	}
+	escaper := htmlAttributeQuoteEscaper
+	if quote == '\'' {
+		escaper = htmlAttributeAposEscaper
+	}
+	err = multiWrite(lc.w, raw[:len(raw)-len(attrValue)], []byte{quote})
+	if err != nil {
+		return err
+	}
+	_, err = escaper.WriteString(lc.w, newValue)
+	if err != nil {
+		return err
+	}
+	_, err = lc.w.Write([]byte{quote})
+	return err
+}
+
+func (lc *xmlRewriter) processText() error {
+	newValue, err := lc.policy.text(stdhtml.UnescapeString(string(lc.rawData())))
+	switch {
+	case errors.Is(err, ErrNotModified):
+		return lc.copy()
+	case err != nil:
+		return err
+	}
+	_, err = textContentHTMLEscaper.WriteString(lc.w, newValue)
+	return err
+}
+
+func (lc *xmlRewriter) processCDATA() error {
+	raw := lc.rawData()
+	const cdataStart, cdataEnd = "<![CDATA[", "]]>"
+	if !bytes.HasPrefix(raw, []byte(cdataStart)) || !bytes.HasSuffix(raw, []byte(cdataEnd)) {
+		return fmt.Errorf("unterminated CDATA section")
+	}
+	newValue, err := lc.policy.text(string(raw[len(cdataStart) : len(raw)-len(cdataEnd)]))
+	switch {
+	case errors.Is(err, ErrNotModified):
+		return lc.copy()
+	case err != nil:
+		return err
+	}
+	if strings.Contains(newValue, cdataEnd) {
+		return fmt.Errorf("rewritten CDATA section contains %q", cdataEnd)
+	}
+	_, err = io.WriteString(lc.w, cdataStart+newValue+cdataEnd)
+	return err
+}
@@ -0,0 +1,209 @@
+// Package robots implements parsing and matching of robots.txt files.
+//
+// See https://www.rfc-editor.org/rfc/rfc9309.html
+package robots
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Robots is a parsed robots.txt file.
+type Robots struct {
+	groups []*group
+	// Sitemaps contains URLs listed in Sitemap lines.
+	Sitemaps []string
+}
+
+type group struct {
+	userAgents []string
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+// Parse parses robots.txt data.
+// Lines that can't be parsed are ignored.
+func Parse(data []byte) *Robots {
+	r := &Robots{}
+	var current *group
+	// lastUserAgent is true if the previous significant line was a user-agent line.
+	lastUserAgent := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:colon]))
+		value := strings.TrimSpace(line[colon+1:])
+		switch key {
+		case "user-agent":
+			if current == nil || !lastUserAgent {
+				current = &group{}
+				r.groups = append(r.groups, current)
+			}
+			current.userAgents = append(current.userAgents, value)
+			lastUserAgent = true
+			continue
+		case "allow", "disallow":
+			if current != nil && value != "" {
+				current.rules = append(current.rules, rule{
+					allow:   key == "allow",
+					pattern: value,
+				})
+			}
+		case "sitemap":
+			// Sitemap lines don't belong to any group.
+			if value != "" {
+				r.Sitemaps = append(r.Sitemaps, value)
+			}
+			continue
+		case "crawl-delay":
+			if current != nil {
+				seconds, err := strconv.ParseFloat(value, 64)
+				if err == nil && seconds >= 0 {
+					current.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+		lastUserAgent = false
+	}
+	return r
+}
+
+// Group returns rules that apply to the given User-Agent string.
+// Groups for the product token of userAgent are used if present, otherwise groups for * are used.
+// If no group matches, the returned Group allows everything.
+func (r *Robots) Group(userAgent string) *Group {
+	token := productToken(userAgent)
+	var matched, wildcard Group
+	for _, g := range r.groups {
+		switch {
+		case token != "" && g.hasUserAgent(token):
+			matched.merge(g)
+		case g.hasUserAgent("*"):
+			wildcard.merge(g)
+		}
+	}
+	if matched.matchedGroups > 0 {
+		return &matched
+	}
+	return &wildcard
+}
+
+func (g *group) hasUserAgent(name string) bool {
+	for _, ua := range g.userAgents {
+		if strings.EqualFold(ua, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// productToken returns the name of the crawler from User-Agent header value.
+// For example, it returns "sitetostatic" for "sitetostatic/1.0 (+https://example.com)".
+func productToken(userAgent string) string {
+	end := strings.IndexAny(userAgent, "/ ")
+	if end >= 0 {
+		userAgent = userAgent[:end]
+	}
+	return userAgent
+}
+
+// Group contains rules from robots.txt that apply to a single crawler.
+type Group struct {
+	rules []rule
+	// CrawlDelay is the delay between requests the site asked for. Zero if not specified.
+	CrawlDelay    time.Duration
+	matchedGroups int
+}
+
+func (g *Group) merge(other *group) {
+	g.rules = append(g.rules, other.rules...)
+	if other.crawlDelay > g.CrawlDelay {
+		g.CrawlDelay = other.crawlDelay
+	}
+	g.matchedGroups++
+}
+
+// AllowAll returns a Group that allows all URLs.
+// It should be used when robots.txt is not available.
+func AllowAll() *Group {
+	return &Group{}
+}
+
+// DisallowAll returns a Group that disallows all URLs.
+// It should be used when robots.txt is unreachable because of server errors.
+func DisallowAll() *Group {
+	return &Group{rules: []rule{{allow: false, pattern: "/"}}}
+}
+
+// Allowed returns whether the crawler may fetch u.
+// The most specific (longest) matching rule wins, allow wins if there are several matching rules of the same length.
+func (g *Group) Allowed(u *url.URL) bool {
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	if p == "/robots.txt" {
+		return true
+	}
+	if u.RawQuery != "" {
+		p += "?" + u.RawQuery
+	}
+	allowed := true
+	matchedLength := -1
+	for _, r := range g.rules {
+		if !matchPattern(r.pattern, p) {
+			continue
+		}
+		if len(r.pattern) > matchedLength || (len(r.pattern) == matchedLength && r.allow) {
+			allowed = r.allow
+			matchedLength = len(r.pattern)
+		}
+	}
+	return allowed
+}
+
+// matchPattern returns whether the path matches the pattern.
+// * in the pattern matches any sequence of characters, $ at the end of the pattern matches end of the path.
+// Otherwise the pattern matches path prefix.
+func matchPattern(pattern, p string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(p, parts[0]) {
+		return false
+	}
+	p = p[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || p == ""
+	}
+	for i, part := range parts[1:] {
+		last := i == len(parts)-2
+		if last && anchored {
+			return strings.HasSuffix(p, part)
+		}
+		idx := strings.Index(p, part)
+		if idx < 0 {
+			return false
+		}
+		p = p[idx+len(part):]
+	}
+	return true
+}
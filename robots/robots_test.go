@@ -0,0 +1,179 @@
+package robots
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRobots = `# comment
+User-agent: *
+Disallow: /private/
+Allow: /private/public.html
+Disallow: /*.pdf$
+Disallow: /search?
+Crawl-delay: 2
+
+User-agent: sitetostatic
+User-agent: othercrawler
+Disallow: /admin # inline comment
+Allow: /admin/login
+Crawl-delay: 0.5
+
+Sitemap: https://example.com/sitemap.xml
+
+User-agent: blocked
+Disallow: /
+Sitemap: https://cdn.example.com/sitemap-index.xml.gz
+`
+
+func TestGroup_Allowed(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		url       string
+		allowed   bool
+	}{
+		{
+			name:      "wildcard root",
+			userAgent: "somebot/1.0",
+			url:       "https://example.com/",
+			allowed:   true,
+		},
+		{
+			name:      "wildcard empty path",
+			userAgent: "somebot/1.0",
+			url:       "https://example.com",
+			allowed:   true,
+		},
+		{
+			name:      "wildcard disallowed prefix",
+			userAgent: "somebot/1.0",
+			url:       "https://example.com/private/a.html",
+			allowed:   false,
+		},
+		{
+			name:      "wildcard allow is more specific",
+			userAgent: "somebot/1.0",
+			url:       "https://example.com/private/public.html",
+			allowed:   true,
+		},
+		{
+			name:      "wildcard pattern with end anchor",
+			userAgent: "somebot/1.0",
+			url:       "https://example.com/docs/a.pdf",
+			allowed:   false,
+		},
+		{
+			name:      "wildcard pattern with end anchor not at end",
+			userAgent: "somebot/1.0",
+			url:       "https://example.com/docs/a.pdf.html",
+			allowed:   true,
+		},
+		{
+			name:      "wildcard query string",
+			userAgent: "somebot/1.0",
+			url:       "https://example.com/search?q=test",
+			allowed:   false,
+		},
+		{
+			name:      "wildcard no query string",
+			userAgent: "somebot/1.0",
+			url:       "https://example.com/search",
+			allowed:   true,
+		},
+		{
+			name:      "specific agent ignores wildcard group",
+			userAgent: "sitetostatic/1.0",
+			url:       "https://example.com/private/a.html",
+			allowed:   true,
+		},
+		{
+			name:      "specific agent case insensitive",
+			userAgent: "SiteToStatic",
+			url:       "https://example.com/admin/users",
+			allowed:   false,
+		},
+		{
+			name:      "specific agent allow",
+			userAgent: "sitetostatic",
+			url:       "https://example.com/admin/login",
+			allowed:   true,
+		},
+		{
+			name:      "second agent in group",
+			userAgent: "othercrawler",
+			url:       "https://example.com/admin",
+			allowed:   false,
+		},
+		{
+			name:      "blocked agent",
+			userAgent: "blocked/2.0",
+			url:       "https://example.com/index.html",
+			allowed:   false,
+		},
+		{
+			name:      "robots.txt always allowed",
+			userAgent: "blocked/2.0",
+			url:       "https://example.com/robots.txt",
+			allowed:   true,
+		},
+	}
+	robots := Parse([]byte(testRobots))
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			u, err := url.Parse(test.url)
+			require.NoError(t, err)
+			assert.Equal(t, test.allowed, robots.Group(test.userAgent).Allowed(u))
+		})
+	}
+}
+
+func TestGroup_CrawlDelay(t *testing.T) {
+	robots := Parse([]byte(testRobots))
+	assert.Equal(t, 2*time.Second, robots.Group("somebot").CrawlDelay)
+	assert.Equal(t, 500*time.Millisecond, robots.Group("sitetostatic").CrawlDelay)
+	assert.Equal(t, time.Duration(0), robots.Group("blocked").CrawlDelay)
+}
+
+func TestParse_Sitemaps(t *testing.T) {
+	robots := Parse([]byte(testRobots))
+	assert.Equal(t, []string{
+		"https://example.com/sitemap.xml",
+		"https://cdn.example.com/sitemap-index.xml.gz",
+	}, robots.Sitemaps)
+	assert.False(t, robots.Group("blocked").Allowed(&url.URL{Path: "/a"}))
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{pattern: "/", path: "/", match: true},
+		{pattern: "/a", path: "/abc", match: true},
+		{pattern: "/a$", path: "/abc", match: false},
+		{pattern: "/a$", path: "/a", match: true},
+		{pattern: "/*/c", path: "/a/b/c", match: true},
+		{pattern: "/*/c$", path: "/a/b/c/d", match: false},
+		{pattern: "/*c*e", path: "/abcde", match: true},
+		{pattern: "/*c*e$", path: "/abcdef", match: false},
+		{pattern: "*.php", path: "/index.php?a=b", match: true},
+		{pattern: "/b", path: "/abc", match: false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.match, matchPattern(test.pattern, test.path), "%q %q", test.pattern, test.path)
+	}
+}
+
+func TestAllowAllDisallowAll(t *testing.T) {
+	u, err := url.Parse("https://example.com/a.html")
+	require.NoError(t, err)
+	assert.True(t, AllowAll().Allowed(u))
+	assert.False(t, DisallowAll().Allowed(u))
+}
@@ -0,0 +1,41 @@
+// Package rules implements parsing shared by the rule and attribute files: line-based files and glob patterns.
+package rules
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ParseLines calls parseLine for each line of r with surrounding whitespace removed.
+// Empty lines and lines starting with # are ignored.
+// Errors returned by parseLine are prefixed with the line number.
+func ParseLines(r io.Reader, parseLine func(line string) error) error {
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		err := parseLine(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// CompileGlob converts glob pattern to an anchored regular expression with a capture group for each *.
+//
+// * matches any sequence of characters including /, all other characters including ? match literally.
+func CompileGlob(glob string) *regexp.Regexp {
+	parts := strings.Split(glob, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, "(.*)") + "$")
+}
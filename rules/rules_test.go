@@ -0,0 +1,48 @@
+package rules
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLines(t *testing.T) {
+	var lines []string
+	err := ParseLines(strings.NewReader("# comment\n\n  a b \n\t#indented comment\nc\n"), func(line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a b", "c"}, lines)
+
+	err = ParseLines(strings.NewReader("a\n\nbad\n"), func(line string) error {
+		if line == "bad" {
+			return errors.New("bad rule")
+		}
+		return nil
+	})
+	assert.EqualError(t, err, "line 3: bad rule")
+}
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		glob, key string
+		match     bool
+	}{
+		{"http://example.com/*", "http://example.com/a/b", true},
+		{"http://example.com/*", "https://example.com/a", false},
+		{"*/a?b=1", "http://example.com/a?b=1", true},
+		{"*/a?b=1", "http://example.com/axb=1", false},
+		{"*.pdf", "http://example.com/doc.pdf?x=1", false},
+		{"*.pdf*", "http://example.com/doc.pdf?x=1", true},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.match, CompileGlob(test.glob).MatchString(test.key), "%s %s", test.glob, test.key)
+	}
+
+	m := CompileGlob("http://example.com/*/*.html").FindStringSubmatch("http://example.com/a/b/c.html")
+	assert.Equal(t, []string{"http://example.com/a/b/c.html", "a/b", "c"}, m)
+}
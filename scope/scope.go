@@ -0,0 +1,136 @@
+// Package scope implements ordered include/exclude rules deciding which URLs to scrape.
+//
+// Each rule has a pattern matched against the storage key of a URL (see repository.Key)
+// and optionally a list of media types it applies to.
+// Rules are evaluated in order and the first matching rule decides.
+package scope
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/martin-sucha/site-to-static/rules"
+)
+
+// Rule includes or excludes URLs with keys matching Pattern.
+type Rule struct {
+	// Include is true if matching URLs are scraped, false if they are excluded.
+	Include bool
+	// Pattern is matched against the URL key.
+	Pattern *regexp.Regexp
+	// MediaTypes limits the rule to responses with one of the media types.
+	// A media type may end with /* to match all subtypes, for example image/*.
+	// Empty means the rule applies to all URLs.
+	MediaTypes []string
+}
+
+// ParseRule parses a rule in format {+|-}{re|glob}:pattern[ type=mediatype[,mediatype...]].
+//
+// + includes matching URLs, - excludes them.
+// re: patterns are regular expressions that match anywhere in the key, use ^ and $ to anchor them.
+// glob: patterns must match the whole key, * matches any sequence of characters including /.
+// All other characters in glob patterns, including ?, match literally.
+func ParseRule(s string) (Rule, error) {
+	var rule Rule
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return rule, fmt.Errorf("empty rule")
+	}
+	switch s[0] {
+	case '+':
+		rule.Include = true
+	case '-':
+		rule.Include = false
+	default:
+		return rule, fmt.Errorf("rule %q must start with + or -", s)
+	}
+	s = s[1:]
+	if idx := strings.LastIndex(s, " type="); idx >= 0 {
+		for _, mt := range strings.Split(s[idx+len(" type="):], ",") {
+			mt = strings.ToLower(strings.TrimSpace(mt))
+			if mt == "" {
+				return rule, fmt.Errorf("rule %q: empty media type", s)
+			}
+			rule.MediaTypes = append(rule.MediaTypes, mt)
+		}
+		s = strings.TrimSpace(s[:idx])
+	}
+	var err error
+	switch {
+	case strings.HasPrefix(s, "re:"):
+		rule.Pattern, err = regexp.Compile(s[len("re:"):])
+		if err != nil {
+			return rule, fmt.Errorf("rule %q: %v", s, err)
+		}
+	case strings.HasPrefix(s, "glob:"):
+		rule.Pattern = rules.CompileGlob(s[len("glob:"):])
+	default:
+		return rule, fmt.Errorf("rule %q: pattern must start with re: or glob:", s)
+	}
+	return rule, nil
+}
+
+// ParseRules parses rules from r, one rule per line.
+// Empty lines and lines starting with # are ignored.
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var out []Rule
+	err := rules.ParseLines(r, func(line string) error {
+		rule, err := ParseRule(line)
+		if err != nil {
+			return err
+		}
+		out = append(out, rule)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Matcher evaluates an ordered list of rules.
+type Matcher struct {
+	Rules []Rule
+}
+
+// Match returns whether the URL with the key and media type should be scraped according to the first matching rule.
+// ok is false if no rule matches.
+//
+// mediatype is empty if it is not known yet, before the URL is downloaded.
+// In that case rules with MediaTypes that include URLs match, as the response might have one of the media types,
+// and rules with MediaTypes that exclude URLs are skipped.
+// The result should be confirmed by calling Match again once the media type is known.
+func (m *Matcher) Match(key, mediatype string) (include, ok bool) {
+	mediatype = strings.ToLower(mediatype)
+	for i := range m.Rules {
+		rule := &m.Rules[i]
+		if len(rule.MediaTypes) > 0 && !matchMediaType(rule.MediaTypes, mediatype) &&
+			!(mediatype == "" && rule.Include) {
+			continue
+		}
+		if rule.Pattern.MatchString(key) {
+			return rule.Include, true
+		}
+	}
+	return false, false
+}
+
+func matchMediaType(patterns []string, mediatype string) bool {
+	if mediatype == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "/*") {
+			if strings.HasPrefix(mediatype, pattern[:len(pattern)-1]) {
+				return true
+			}
+			continue
+		}
+		if pattern == mediatype {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package scope
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRule(t *testing.T) {
+	rule, err := ParseRule("-glob:*/blog/tag/* type=text/html,Image/*")
+	require.NoError(t, err)
+	assert.False(t, rule.Include)
+	assert.Equal(t, []string{"text/html", "image/*"}, rule.MediaTypes)
+	assert.True(t, rule.Pattern.MatchString("http://example.com/blog/tag/go"))
+	assert.False(t, rule.Pattern.MatchString("http://example.com/blog/post"))
+
+	rule, err = ParseRule("+re:[?&]page=")
+	require.NoError(t, err)
+	assert.True(t, rule.Include)
+	assert.Nil(t, rule.MediaTypes)
+	assert.True(t, rule.Pattern.MatchString("http://example.com/?page=2"))
+
+	for _, s := range []string{"", "glob:*", "+*", "+re:(", "-glob:* type="} {
+		_, err = ParseRule(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader("# comment\n\n-re:replytocom=\n+glob:http://example.com/blog/*\n"))
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.False(t, rules[0].Include)
+	assert.True(t, rules[1].Include)
+
+	_, err = ParseRules(strings.NewReader("+glob:*\nbad\n"))
+	assert.EqualError(t, err, `line 2: rule "bad" must start with + or -`)
+}
+
+func TestMatcher_Match(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(`
+-glob:http://example.com/blog/tag/*
+-re:[?&]replytocom=
+-glob:* type=application/pdf
++glob:http://example.com/blog/*
++glob:http://cdn.example.com/* type=image/*
+`))
+	require.NoError(t, err)
+	m := &Matcher{Rules: rules}
+	tests := []struct {
+		key, mediatype string
+		include, ok    bool
+	}{
+		{"http://example.com/blog/", "", true, true},
+		{"http://example.com/blog/tag/go", "", false, true},
+		{"http://example.com/blog/post?replytocom=5", "", false, true},
+		{"http://example.com/blog/post.pdf", "", true, true},
+		{"http://example.com/blog/post.pdf", "application/pdf", false, true},
+		{"http://example.com/about", "", false, false},
+		{"http://cdn.example.com/a.png", "", true, true},
+		{"http://cdn.example.com/a.png", "image/png", true, true},
+		{"http://cdn.example.com/a.js", "text/javascript", false, false},
+	}
+	for _, test := range tests {
+		include, ok := m.Match(test.key, test.mediatype)
+		assert.Equal(t, test.include, include, "%s %s", test.key, test.mediatype)
+		assert.Equal(t, test.ok, ok, "%s %s", test.key, test.mediatype)
+	}
+}
@@ -0,0 +1,114 @@
+package scraper
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/martin-sucha/site-to-static/urlnorm"
+
+	"golang.org/x/time/rate"
+)
+
+// HostLimit configures limits of requests sent to a single host.
+type HostLimit struct {
+	// Rate is the maximum number of requests per second.
+	// Zero means no limit.
+	Rate rate.Limit
+	// Burst is the maximum number of requests sent at once if the rate allows.
+	// Zero means one.
+	Burst int
+	// MaxConcurrent is the maximum number of requests to the host in progress at the same time.
+	// Zero means no limit other than the number of workers.
+	MaxConcurrent int
+}
+
+// hostState enforces HostLimit for a single host.
+type hostState struct {
+	limiter *rate.Limiter
+	// slots limits the number of concurrent requests. nil if unlimited.
+	slots chan struct{}
+}
+
+type hostStates struct {
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+// hostLimit returns limits configured for the host (host or host:port).
+func (s *Scraper) hostLimit(host string) HostLimit {
+	if limit, ok := s.HostLimits[host]; ok {
+		return limit
+	}
+	if idx := strings.LastIndexByte(host, ':'); idx >= 0 && !strings.HasSuffix(host, "]") {
+		if limit, ok := s.HostLimits[host[:idx]]; ok {
+			return limit
+		}
+	}
+	return s.DefaultHostLimit
+}
+
+// hostState returns the state of the host of u, creating it if necessary.
+func (s *Scraper) hostState(run *scrapeRun, u *url.URL) *hostState {
+	host := urlnorm.Canonical(u).Host
+	run.hosts.mu.Lock()
+	defer run.hosts.mu.Unlock()
+	hs, ok := run.hosts.hosts[host]
+	if ok {
+		return hs
+	}
+	limit := s.hostLimit(host)
+	limiterRate := limit.Rate
+	if limiterRate == 0 {
+		limiterRate = rate.Inf
+	}
+	burst := limit.Burst
+	if burst == 0 {
+		burst = 1
+	}
+	hs = &hostState{
+		limiter: rate.NewLimiter(limiterRate, burst),
+	}
+	if limit.MaxConcurrent > 0 {
+		hs.slots = make(chan struct{}, limit.MaxConcurrent)
+	}
+	run.hosts.hosts[host] = hs
+	return hs
+}
+
+// acquireHost waits until a request to the host of u may be sent.
+// The returned release function must be called once the request is complete.
+func (s *Scraper) acquireHost(ctx context.Context, run *scrapeRun, u *url.URL) (release func(), err error) {
+	hs := s.hostState(run, u)
+	release = func() {}
+	if hs.slots != nil {
+		select {
+		case hs.slots <- struct{}{}:
+			release = func() {
+				<-hs.slots
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	err = hs.limiter.Wait(ctx)
+	if err == nil && s.Limiter != nil {
+		err = s.Limiter.Wait(ctx)
+	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// applyCrawlDelay slows down requests to the host if the host asks for it.
+func (hs *hostState) applyCrawlDelay(delay time.Duration) {
+	limit := rate.Every(delay)
+	if limit < hs.limiter.Limit() {
+		hs.limiter.SetLimit(limit)
+		hs.limiter.SetBurst(1)
+	}
+}
@@ -0,0 +1,68 @@
+package scraper
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestScraper_HostLimit(t *testing.T) {
+	s := &Scraper{
+		DefaultHostLimit: HostLimit{Rate: 10},
+		HostLimits: map[string]HostLimit{
+			"example.com":      {Rate: 1},
+			"example.com:8080": {Rate: 2},
+			"[::1]":            {Rate: 3},
+		},
+	}
+	assert.Equal(t, rate.Limit(1), s.hostLimit("example.com").Rate)
+	assert.Equal(t, rate.Limit(1), s.hostLimit("example.com:8081").Rate)
+	assert.Equal(t, rate.Limit(2), s.hostLimit("example.com:8080").Rate)
+	assert.Equal(t, rate.Limit(3), s.hostLimit("[::1]").Rate)
+	assert.Equal(t, rate.Limit(3), s.hostLimit("[::1]:8080").Rate)
+	assert.Equal(t, rate.Limit(10), s.hostLimit("example.net").Rate)
+}
+
+func TestScraper_AcquireHost(t *testing.T) {
+	s := &Scraper{
+		DefaultHostLimit: HostLimit{MaxConcurrent: 1},
+	}
+	run := &scrapeRun{
+		hosts: hostStates{
+			hosts: make(map[string]*hostState),
+		},
+	}
+	u, err := url.Parse("https://Example.com:443/a.html")
+	require.NoError(t, err)
+	u2, err := url.Parse("https://example.com/b.html")
+	require.NoError(t, err)
+
+	release, err := s.acquireHost(context.Background(), run, u)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = s.acquireHost(ctx, run, u2)
+	assert.Equal(t, context.DeadlineExceeded, err, "second request to the same host should wait")
+
+	release()
+	release2, err := s.acquireHost(context.Background(), run, u2)
+	require.NoError(t, err)
+	release2()
+}
+
+func TestHostState_ApplyCrawlDelay(t *testing.T) {
+	hs := &hostState{limiter: rate.NewLimiter(10, 5)}
+	hs.applyCrawlDelay(2 * time.Second)
+	assert.Equal(t, rate.Limit(0.5), hs.limiter.Limit())
+	assert.Equal(t, 1, hs.limiter.Burst())
+
+	hs = &hostState{limiter: rate.NewLimiter(0.1, 1)}
+	hs.applyCrawlDelay(2 * time.Second)
+	assert.Equal(t, rate.Limit(0.1), hs.limiter.Limit(), "configured rate is slower than crawl delay")
+}
@@ -0,0 +1,232 @@
+package scraper
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/martin-sucha/site-to-static/repository"
+)
+
+func TestSetConditionalHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		previous    repository.DocumentMetadata
+		expected    http.Header
+		conditional bool
+	}{
+		{
+			name: "etag and last-modified",
+			previous: repository.DocumentMetadata{StatusCode: 200, Headers: http.Header{
+				"Etag":          {`"v1"`},
+				"Last-Modified": {"Mon, 02 Jan 2006 15:04:05 GMT"},
+			}},
+			expected: http.Header{
+				"If-None-Match":     {`"v1"`},
+				"If-Modified-Since": {"Mon, 02 Jan 2006 15:04:05 GMT"},
+			},
+			conditional: true,
+		},
+		{
+			name:        "etag",
+			previous:    repository.DocumentMetadata{StatusCode: 200, Headers: http.Header{"Etag": {`W/"v1"`}}},
+			expected:    http.Header{"If-None-Match": {`W/"v1"`}},
+			conditional: true,
+		},
+		{
+			name:     "no validators",
+			previous: repository.DocumentMetadata{StatusCode: 200, Headers: http.Header{}},
+			expected: http.Header{},
+		},
+		{
+			name:     "not ok",
+			previous: repository.DocumentMetadata{StatusCode: 404, Headers: http.Header{"Etag": {`"v1"`}}},
+			expected: http.Header{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := http.Header{}
+			conditional := setConditionalHeaders(header, &test.previous)
+			assert.Equal(t, test.conditional, conditional)
+			assert.Equal(t, test.expected, header)
+		})
+	}
+}
+
+// incrementalServer serves pages with validators and records conditional headers of requests.
+type incrementalServer struct {
+	mu sync.Mutex
+	// conditional maps request path to If-None-Match and If-Modified-Since values of the last request.
+	conditional map[string][2]string
+}
+
+const lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+func (is *incrementalServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	is.mu.Lock()
+	is.conditional[r.URL.Path] = [2]string{r.Header.Get("If-None-Match"), r.Header.Get("If-Modified-Since")}
+	is.mu.Unlock()
+	w.Header().Set("Content-Type", "text/html")
+	switch r.URL.Path {
+	case "/etag":
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte(`<a href="/new-link">new</a>`))
+	case "/last-modified":
+		w.Header().Set("Last-Modified", lastModified)
+		if r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte(`<a href="/new-link">new</a>`))
+	case "/same":
+		_, _ = w.Write([]byte(`same`))
+	case "/changed":
+		_, _ = w.Write([]byte(`changed`))
+	case "/moved":
+		http.Redirect(w, r, "/target", http.StatusMovedPermanently)
+	case "/target":
+		_, _ = w.Write([]byte(`target`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestScraper_Incremental(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scraper-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	repo := repository.New(dir)
+
+	is := &incrementalServer{conditional: make(map[string][2]string)}
+	server := httptest.NewServer(is)
+	defer server.Close()
+
+	previousTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := func(path string, headers http.Header, body string) {
+		u := server.URL + path
+		parsedURL, err := url.Parse(u)
+		require.NoError(t, err)
+		headers.Set("Content-Type", "text/html")
+		dw, err := repo.NewWriter()
+		require.NoError(t, err)
+		_, err = dw.Write([]byte(body))
+		require.NoError(t, err)
+		require.NoError(t, dw.Close(&repository.DocumentMetadata{
+			Key:                 repository.Key(parsedURL),
+			DownloadStartedTime: previousTime,
+			URL:                 u,
+			StatusCode:          200,
+			Headers:             headers,
+		}))
+	}
+	store("/etag", http.Header{"Etag": {`"v1"`}}, `<a href="/etag-link">old</a>`)
+	store("/last-modified", http.Header{"Last-Modified": {lastModified}}, `<a href="/last-modified-link">old</a>`)
+	store("/same", http.Header{}, `same`)
+	store("/changed", http.Header{}, `old`)
+	store("/moved", http.Header{"Etag": {`"moved"`}}, `moved`)
+
+	newTasks := make(chan *task, 100)
+	doneTasks := make(chan *task, 100)
+	s := &Scraper{
+		Client:       *server.Client(),
+		Repository:   repo,
+		IgnoreRobots: true,
+		Incremental:  true,
+		FollowURL: func(u *url.URL) bool {
+			return true
+		},
+	}
+	run := &scrapeRun{
+		newTasks:    newTasks,
+		doneTasks:   doneTasks,
+		startedTime: time.Now(),
+		hosts:       hostStates{hosts: make(map[string]*hostState)},
+		limitStop:   make(chan struct{}),
+	}
+	for _, path := range []string{"/etag", "/last-modified", "/same", "/changed", "/moved"} {
+		u, err := url.Parse(server.URL + path)
+		require.NoError(t, err)
+		err = s.scrapeTask(context.Background(), run, &task{downloadURL: u, key: repository.Key(u)})
+		require.NoError(t, err, path)
+	}
+	close(newTasks)
+
+	assert.Equal(t, [2]string{`"v1"`, ""}, is.conditional["/etag"])
+	assert.Equal(t, [2]string{"", lastModified}, is.conditional["/last-modified"])
+	assert.Equal(t, [2]string{"", ""}, is.conditional["/same"])
+	assert.Equal(t, [2]string{`"moved"`, ""}, is.conditional["/moved"])
+	// Validators of the redirecting document are not sent to the redirect target.
+	assert.Equal(t, [2]string{"", ""}, is.conditional["/target"])
+
+	// Not modified documents are kept as they were, only the download time is updated.
+	for path, body := range map[string]string{
+		"/etag":          `<a href="/etag-link">old</a>`,
+		"/last-modified": `<a href="/last-modified-link">old</a>`,
+	} {
+		u, err := url.Parse(server.URL + path)
+		require.NoError(t, err)
+		doc, err := repo.Load(repository.Key(u))
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(doc.Body())
+		require.NoError(t, err)
+		assert.Equal(t, body, string(data), path)
+		assert.False(t, doc.Metadata.DownloadStartedTime.Before(run.startedTime), path)
+		require.NoError(t, doc.Close())
+	}
+
+	// Links are still extracted from not modified documents.
+	// The redirect is followed by the HTTP client, so /target is not a new task.
+	var links []string
+	for nt := range newTasks {
+		links = append(links, nt.downloadURL.Path)
+	}
+	sort.Strings(links)
+	assert.Equal(t, []string{"/etag-link", "/last-modified-link"}, links)
+
+	// /etag, /last-modified (not modified) and /same (same body) are unchanged,
+	// /changed, /moved (now a redirect) and /target (new) are changed.
+	assert.Equal(t, int64(3), run.unchangedDocuments)
+	assert.Equal(t, int64(3), run.changedDocuments)
+
+	// A resumed scrape doesn't request documents that were already checked in the same run,
+	// links are extracted from the stored documents.
+	is.conditional = make(map[string][2]string)
+	s.Resume = true
+	newTasks = make(chan *task, 100)
+	resumed := &scrapeRun{
+		newTasks:    newTasks,
+		doneTasks:   doneTasks,
+		startedTime: run.startedTime,
+		hosts:       hostStates{hosts: make(map[string]*hostState)},
+		limitStop:   make(chan struct{}),
+	}
+	for _, path := range []string{"/etag", "/last-modified", "/same"} {
+		u, err := url.Parse(server.URL + path)
+		require.NoError(t, err)
+		err = s.scrapeTask(context.Background(), resumed, &task{downloadURL: u, key: repository.Key(u)})
+		require.NoError(t, err, path)
+	}
+	close(newTasks)
+	assert.Empty(t, is.conditional)
+	links = nil
+	for nt := range newTasks {
+		links = append(links, nt.downloadURL.Path)
+	}
+	sort.Strings(links)
+	assert.Equal(t, []string{"/etag-link", "/last-modified-link"}, links)
+}
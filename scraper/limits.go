@@ -0,0 +1,38 @@
+package scraper
+
+import "sync/atomic"
+
+// reservePage counts a download towards MaxPages.
+// Returns false if MaxPages or MaxBytes was already reached and the download should not be started.
+func (s *Scraper) reservePage(run *scrapeRun) bool {
+	if s.MaxBytes > 0 && atomic.LoadInt64(&run.downloadedBytes) >= s.MaxBytes {
+		run.stopForLimit("max bytes limit")
+		return false
+	}
+	pages := atomic.AddInt64(&run.downloadedPages, 1)
+	if s.MaxPages > 0 && pages > s.MaxPages {
+		atomic.AddInt64(&run.downloadedPages, -1)
+		run.stopForLimit("max pages limit")
+		return false
+	}
+	if s.MaxPages > 0 && pages == s.MaxPages {
+		run.stopForLimit("max pages limit")
+	}
+	return true
+}
+
+// addDownloadedBytes counts body bytes towards MaxBytes.
+func (s *Scraper) addDownloadedBytes(run *scrapeRun, n int64) {
+	total := atomic.AddInt64(&run.downloadedBytes, n)
+	if s.MaxBytes > 0 && total >= s.MaxBytes {
+		run.stopForLimit("max bytes limit")
+	}
+}
+
+// stopForLimit stops sending new tasks to workers. Tasks in progress are completed.
+func (run *scrapeRun) stopForLimit(reason string) {
+	run.limitOnce.Do(func() {
+		run.limitReason = reason
+		close(run.limitStop)
+	})
+}
@@ -0,0 +1,62 @@
+package scraper
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScraper_ReservePage(t *testing.T) {
+	s := &Scraper{MaxPages: 2}
+	run := &scrapeRun{limitStop: make(chan struct{})}
+	assert.True(t, s.reservePage(run))
+	assert.Equal(t, "", run.limitReason)
+	assert.True(t, s.reservePage(run))
+	assert.Equal(t, "max pages limit", run.limitReason)
+	assert.False(t, s.reservePage(run))
+	assert.Equal(t, int64(2), run.downloadedPages)
+	select {
+	case <-run.limitStop:
+	default:
+		t.Error("limitStop not closed")
+	}
+}
+
+func TestScraper_AddDownloadedBytes(t *testing.T) {
+	s := &Scraper{MaxBytes: 100}
+	run := &scrapeRun{limitStop: make(chan struct{})}
+	s.addDownloadedBytes(run, 60)
+	assert.True(t, s.reservePage(run))
+	s.addDownloadedBytes(run, 60)
+	assert.Equal(t, "max bytes limit", run.limitReason)
+	assert.False(t, s.reservePage(run))
+}
+
+func TestScraper_FollowURLDepth(t *testing.T) {
+	newTasks := make(chan *task, 10)
+	s := &Scraper{
+		MaxDepth: 2,
+		FollowURL: func(u *url.URL) bool {
+			return true
+		},
+	}
+	run := &scrapeRun{newTasks: newTasks}
+	docURL, err := url.Parse("http://example.com/a/")
+	require.NoError(t, err)
+
+	s.followURL(docURL, "b", docURL, 2, run)
+	s.followURL(docURL, "c", docURL, 3, run)
+	close(newTasks)
+
+	var tasks []*task
+	for nt := range newTasks {
+		tasks = append(tasks, nt)
+	}
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "http://example.com/a/b", tasks[0].downloadURL.String())
+	assert.Equal(t, 2, tasks[0].depth)
+	assert.Equal(t, "http://example.com/a/", tasks[0].referrer)
+	assert.Equal(t, int64(1), run.depthLimited)
+}
@@ -3,34 +3,85 @@ package scraper
 import (
 	"fmt"
 	"net/url"
+	"time"
 )
 
 type task struct {
 	downloadURL *url.URL
 	key         string
+	// sitemap is true if the task is to download a sitemap and scrape pages listed in it.
+	sitemap bool
+	// depth is the number of links followed from an initial URL or sitemap.
+	depth int
+	// referrer is the URL of the document that linked to downloadURL. Empty for initial tasks.
+	referrer string
+	// interrupted is set when the task was not completed because the scrape is stopping.
+	// Such task is kept pending when it is marked as done.
+	interrupted bool
 	next        *task
 }
 
+// queueOptions configure optional behavior of queue.
+type queueOptions struct {
+	// seenKeys are keys of tasks that were already added in a previous run.
+	// Tasks with these keys are not added again, unless they are in initialTasks.
+	seenKeys []string
+	// checkpointTick triggers a call to checkpoint each time it receives a value.
+	checkpointTick <-chan time.Time
+	// checkpoint receives incomplete tasks and keys of all tasks seen so far.
+	// It is called on each checkpointTick and once more when the queue finishes.
+	checkpoint func(pending []*task, seenKeys []string)
+	// stop makes the queue stop sending tasks when closed.
+	// The queue then exits as soon as all tasks that were sent are marked as done.
+	stop <-chan struct{}
+}
+
 // queue implements a task queue.
 // It runs as long as there it at least one incomplete task.
 // New tasks are posted to in and can be read out from out.
 // A task is marked as complete by sending it to doneTask.
-func queue(initialTasks []*task, in <-chan *task, doneTask <-chan *task, out chan<- *task) {
-	addedKeys := make(map[string]struct{})
+func queue(initialTasks []*task, in <-chan *task, doneTask <-chan *task, out chan<- *task, opts queueOptions) {
+	addedKeys := make(map[string]struct{}, len(opts.seenKeys)+len(initialTasks))
+	for _, key := range opts.seenKeys {
+		addedKeys[key] = struct{}{}
+	}
 	var q linkedQueue
+	incompleteTasks := 0
+	initialKeys := make(map[string]struct{}, len(initialTasks))
 	for _, t := range initialTasks {
-		if _, ok := addedKeys[t.key]; ok {
+		if _, ok := initialKeys[t.key]; ok {
 			// already added this key, skip it
 			continue
 		}
+		initialKeys[t.key] = struct{}{}
+		addedKeys[t.key] = struct{}{}
 		q.pushRight(t)
+		incompleteTasks++
+	}
+	// inFlight contains tasks that were sent to out, but not marked as done yet.
+	inFlight := make(map[*task]struct{})
+	checkpoint := func() {
+		if opts.checkpoint == nil {
+			return
+		}
+		pending := make([]*task, 0, len(inFlight)+q.len())
+		for t := range inFlight {
+			pending = append(pending, t)
+		}
+		pending = append(pending, q.toSlice()...)
+		seenKeys := make([]string, 0, len(addedKeys))
+		for key := range addedKeys {
+			seenKeys = append(seenKeys, key)
+		}
+		opts.checkpoint(pending, seenKeys)
 	}
-	incompleteTasks := len(initialTasks)
+	stop := opts.stop
+	stopped := false
 Loop:
-	for incompleteTasks > 0 {
+	for incompleteTasks > 0 && !(stopped && len(inFlight) == 0) {
 		var sendChan chan<- *task
 		currentTask := q.popLeft()
-		if currentTask != nil {
+		if currentTask != nil && !stopped {
 			sendChan = out
 		}
 		select {
@@ -52,16 +103,39 @@ Loop:
 			incompleteTasks++
 		case sendChan <- currentTask:
 			// successfully sent
-		case _, ok := <-doneTask:
+			inFlight[currentTask] = struct{}{}
+		case t, ok := <-doneTask:
 			if currentTask != nil {
 				// need to restore the task for next iteration.
 				q.pushLeft(currentTask)
 			}
-			if ok {
-				incompleteTasks--
+			if !ok {
+				continue Loop
+			}
+			delete(inFlight, t)
+			if t.interrupted {
+				// keep the task pending so that it is stored in the checkpoint.
+				t.interrupted = false
+				q.pushRight(t)
+				continue Loop
+			}
+			incompleteTasks--
+		case <-opts.checkpointTick:
+			if currentTask != nil {
+				// need to restore the task for next iteration.
+				q.pushLeft(currentTask)
+			}
+			checkpoint()
+		case <-stop:
+			if currentTask != nil {
+				// need to restore the task for next iteration.
+				q.pushLeft(currentTask)
 			}
+			stop = nil
+			stopped = true
 		}
 	}
+	checkpoint()
 }
 
 type linkedQueue struct {
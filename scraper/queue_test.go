@@ -1,9 +1,11 @@
 package scraper
 
 import (
+	"sort"
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -25,7 +27,7 @@ func TestQueue(t *testing.T) {
 		defer close(in)
 		defer close(done)
 		defer close(out)
-		queue(initialTasks, in, done, out)
+		queue(initialTasks, in, done, out, queueOptions{})
 	}()
 
 	var receivedKeys []string
@@ -52,6 +54,128 @@ func TestQueue(t *testing.T) {
 	assert.Equal(t, expectedKeys, receivedKeys)
 }
 
+func TestQueue_SeenKeys(t *testing.T) {
+	initialTasks := []*task{
+		{key: "A"},
+		{key: "B"},
+		{key: "A"},
+	}
+	in := make(chan *task)
+	done := make(chan *task)
+	out := make(chan *task)
+
+	go func() {
+		defer close(in)
+		defer close(done)
+		defer close(out)
+		queue(initialTasks, in, done, out, queueOptions{seenKeys: []string{"B", "C"}})
+	}()
+
+	var receivedKeys []string
+	for t := range out {
+		receivedKeys = append(receivedKeys, t.key)
+		if t.key == "A" {
+			in <- &task{key: "C"}
+			in <- &task{key: "D"}
+		}
+		done <- t
+	}
+
+	assert.Equal(t, []string{"A", "B", "D"}, receivedKeys)
+}
+
+func TestQueue_Checkpoint(t *testing.T) {
+	initialTasks := []*task{
+		{key: "A"},
+		{key: "B"},
+	}
+	in := make(chan *task)
+	done := make(chan *task)
+	out := make(chan *task)
+	tick := make(chan time.Time)
+
+	type checkpointData struct {
+		pending  []string
+		seenKeys []string
+	}
+	checkpoints := make(chan checkpointData, 2)
+	opts := queueOptions{
+		checkpointTick: tick,
+		checkpoint: func(pending []*task, seenKeys []string) {
+			pendingKeys := keys(pending)
+			sort.Strings(pendingKeys)
+			sort.Strings(seenKeys)
+			checkpoints <- checkpointData{pending: pendingKeys, seenKeys: seenKeys}
+		},
+	}
+
+	go func() {
+		defer close(in)
+		defer close(done)
+		defer close(out)
+		queue(initialTasks, in, done, out, opts)
+	}()
+
+	taskA := <-out
+	in <- &task{key: "C"}
+	tick <- time.Time{}
+	assert.Equal(t, checkpointData{
+		pending:  []string{"A", "B", "C"},
+		seenKeys: []string{"A", "B", "C"},
+	}, <-checkpoints)
+	done <- taskA
+	for t := range out {
+		done <- t
+	}
+	assert.Equal(t, checkpointData{
+		pending:  []string{},
+		seenKeys: []string{"A", "B", "C"},
+	}, <-checkpoints)
+}
+
+func TestQueue_Stop(t *testing.T) {
+	initialTasks := []*task{
+		{key: "A"},
+		{key: "B"},
+		{key: "C"},
+	}
+	in := make(chan *task)
+	done := make(chan *task)
+	out := make(chan *task)
+	stop := make(chan struct{})
+
+	var checkpointed []string
+	opts := queueOptions{
+		checkpoint: func(pending []*task, seenKeys []string) {
+			checkpointed = keys(pending)
+			sort.Strings(checkpointed)
+		},
+		stop: stop,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(in)
+		defer close(done)
+		defer close(out)
+		queue(initialTasks, in, done, out, opts)
+	}()
+
+	taskA := <-out
+	taskB := <-out
+	close(stop)
+	done <- taskA
+	taskB.interrupted = true
+	done <- taskB
+	_, ok := <-out
+	assert.False(t, ok, "queue should not send more tasks after stop")
+	wg.Wait()
+
+	assert.Equal(t, []string{"B", "C"}, checkpointed)
+}
+
 func TestLinkedQueue_PushRight(t *testing.T) {
 	var lq linkedQueue
 	assert.Equal(t, []string{}, keys(lq.toSlice()))
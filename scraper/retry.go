@@ -0,0 +1,126 @@
+package scraper
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy configures retrying of requests that failed because of transient errors.
+//
+// Connection errors, timeouts, 429 Too Many Requests and 5xx responses are retried.
+// Only the response of the last attempt is stored in the repository.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	// Zero or one disables retries.
+	MaxAttempts int
+	// InitialDelay is the delay before the first retry.
+	// The delay is doubled for every subsequent retry and randomized by up to half of its value.
+	InitialDelay time.Duration
+	// MaxDelay limits the delay between attempts.
+	// If the server asks for a longer delay using Retry-After header, the response is not retried.
+	// Zero means no limit.
+	MaxDelay time.Duration
+}
+
+// retryableError is an error of an attempt that can be retried.
+type retryableError struct {
+	err error
+	// retryAfter is the delay requested by the server. Zero if not specified.
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// delay returns how long to wait after the given number of failed attempts.
+func (p *RetryPolicy) delay(attempts int, retryAfter time.Duration) time.Duration {
+	d := p.InitialDelay
+	for i := 1; i < attempts && (p.MaxDelay == 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d > 0 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+	if retryAfter > d {
+		d = retryAfter
+	}
+	return d
+}
+
+// acceptsRetryAfter returns whether the policy allows waiting for retryAfter.
+func (p *RetryPolicy) acceptsRetryAfter(retryAfter time.Duration) bool {
+	return p.MaxDelay == 0 || retryAfter <= p.MaxDelay
+}
+
+// isRetryableStatus returns whether a response with the status code should be retried.
+func isRetryableStatus(statusCode int) bool {
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return true
+	case statusCode == http.StatusNotImplemented || statusCode == http.StatusHTTPVersionNotSupported:
+		return false
+	default:
+		return 500 <= statusCode && statusCode <= 599
+	}
+}
+
+// isRetryableError returns whether err returned from http.Client or while reading the body is transient.
+func isRetryableError(err error) bool {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		// url.Error implements net.Error itself, look at the cause.
+		err = urlErr.Err
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr):
+		return true
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return true
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EPIPE):
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter parses value of Retry-After header.
+// Returns zero if the value is empty or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0
+	}
+	d := t.Sub(now)
+	if d < 0 {
+		return 0
+	}
+	return d
+}
@@ -0,0 +1,176 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/martin-sucha/site-to-static/repository"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{
+		MaxAttempts:  10,
+		InitialDelay: time.Second,
+		MaxDelay:     10 * time.Second,
+	}
+	tests := []struct {
+		attempts   int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{attempts: 1, min: 500 * time.Millisecond, max: time.Second},
+		{attempts: 2, min: time.Second, max: 2 * time.Second},
+		{attempts: 3, min: 2 * time.Second, max: 4 * time.Second},
+		{attempts: 5, min: 5 * time.Second, max: 10 * time.Second},
+		{attempts: 100, min: 5 * time.Second, max: 10 * time.Second},
+		{attempts: 1, retryAfter: 7 * time.Second, min: 7 * time.Second, max: 7 * time.Second},
+	}
+	for _, test := range tests {
+		for i := 0; i < 100; i++ {
+			d := p.delay(test.attempts, test.retryAfter)
+			if !assert.True(t, test.min <= d && d <= test.max, "attempts %d: %v not in [%v, %v]",
+				test.attempts, d, test.min, test.max) {
+				break
+			}
+		}
+	}
+}
+
+func TestRetryPolicy_AcceptsRetryAfter(t *testing.T) {
+	p := RetryPolicy{MaxDelay: time.Minute}
+	assert.True(t, p.acceptsRetryAfter(0))
+	assert.True(t, p.acceptsRetryAfter(time.Minute))
+	assert.False(t, p.acceptsRetryAfter(time.Hour))
+	assert.True(t, (&RetryPolicy{}).acceptsRetryAfter(time.Hour))
+}
+
+func TestIsRetryableStatus(t *testing.T) {
+	assert.True(t, isRetryableStatus(429))
+	assert.True(t, isRetryableStatus(500))
+	assert.True(t, isRetryableStatus(503))
+	assert.False(t, isRetryableStatus(501))
+	assert.False(t, isRetryableStatus(200))
+	assert.False(t, isRetryableStatus(404))
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{
+			name:      "connection refused",
+			err:       &url.Error{Op: "Get", URL: "http://x", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}},
+			retryable: true,
+		},
+		{
+			name:      "dns not found",
+			err:       &url.Error{Op: "Get", URL: "http://x", Err: &net.DNSError{Err: "no such host", IsNotFound: true}},
+			retryable: false,
+		},
+		{
+			name:      "dns temporary",
+			err:       &url.Error{Op: "Get", URL: "http://x", Err: &net.DNSError{Err: "timeout", IsTimeout: true}},
+			retryable: true,
+		},
+		{
+			name:      "unexpected eof in body",
+			err:       fmt.Errorf("copy: %w", io.ErrUnexpectedEOF),
+			retryable: true,
+		},
+		{
+			name:      "connection reset",
+			err:       fmt.Errorf("read: %w", syscall.ECONNRESET),
+			retryable: true,
+		},
+		{
+			name:      "too many redirects",
+			err:       &url.Error{Op: "Get", URL: "http://x", Err: errors.New("stopped after 10 redirects")},
+			retryable: false,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.retryable, isRetryableError(test.err))
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC)
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-1", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter("Wed, 21 Oct 2015 07:28:30 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("Wed, 21 Oct 2015 07:27:00 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
+
+func TestScraper_BodyResetFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scraper-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	repo := repository.New(dir)
+
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		conn, bufrw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		_, _ = bufrw.WriteString("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Length: 100\r\n\r\n<a href=")
+		_ = bufrw.Flush()
+		// Reset the connection in the middle of the body.
+		_ = conn.(*net.TCPConn).SetLinger(0)
+		_ = conn.Close()
+	}))
+	defer server.Close()
+
+	s := &Scraper{
+		Client:       *server.Client(),
+		Repository:   repo,
+		IgnoreRobots: true,
+		Retry:        RetryPolicy{MaxAttempts: 2, InitialDelay: time.Millisecond},
+	}
+	run := &scrapeRun{
+		newTasks:  make(chan *task, 10),
+		doneTasks: make(chan *task, 10),
+		hosts:     hostStates{hosts: make(map[string]*hostState)},
+		limitStop: make(chan struct{}),
+	}
+	u, err := url.Parse(server.URL + "/page")
+	require.NoError(t, err)
+	err = s.scrapeTask(context.Background(), run, &task{downloadURL: u, key: repository.Key(u), referrer: "ref"})
+	require.Error(t, err)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&requests))
+
+	// The incomplete document is not stored, the failure is.
+	_, err = repo.Load(repository.Key(u))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	failures, err := repo.ListFailures()
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	assert.Equal(t, repository.Key(u), failures[0].Key)
+	assert.Equal(t, u.String(), failures[0].URL)
+	assert.Equal(t, "ref", failures[0].Referrer)
+	assert.Equal(t, 2, failures[0].Attempts)
+	assert.NotEmpty(t, failures[0].Error)
+}
@@ -0,0 +1,121 @@
+package scraper
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/martin-sucha/site-to-static/repository"
+	"github.com/martin-sucha/site-to-static/robots"
+)
+
+// hostRobots contains robots.txt rules for a single scheme and host.
+type hostRobots struct {
+	// ready is closed when the fields below are populated.
+	ready chan struct{}
+	group *robots.Group
+	// err is set if robots.txt could not be fetched because the scrape is stopping.
+	err error
+}
+
+type robotsCache struct {
+	mu    sync.Mutex
+	hosts map[string]*hostRobots
+}
+
+// robotsFor returns robots.txt rules that apply to u, fetching robots.txt if it was not fetched yet.
+func (s *Scraper) robotsFor(ctx context.Context, run *scrapeRun, u *url.URL) (*hostRobots, error) {
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	key := repository.Key(robotsURL)
+
+	run.robots.mu.Lock()
+	hr, ok := run.robots.hosts[key]
+	if !ok {
+		hr = &hostRobots{ready: make(chan struct{})}
+		run.robots.hosts[key] = hr
+	}
+	run.robots.mu.Unlock()
+
+	if ok {
+		select {
+		case <-hr.ready:
+			if hr.err != nil {
+				return nil, hr.err
+			}
+			return hr, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	defer close(hr.ready)
+	group, err := s.fetchRobots(ctx, run, robotsURL)
+	if err != nil {
+		// Don't cache the error, the scrape is stopping anyway.
+		run.robots.mu.Lock()
+		delete(run.robots.hosts, key)
+		run.robots.mu.Unlock()
+		hr.err = err
+		return nil, err
+	}
+	hr.group = group
+	if group.CrawlDelay > 0 {
+		s.hostState(run, u).applyCrawlDelay(group.CrawlDelay)
+	}
+	return hr, nil
+}
+
+// fetchRobots downloads robots.txt, stores it in the repository and returns rules for our user agent.
+// An error is returned only if ctx is cancelled.
+func (s *Scraper) fetchRobots(ctx context.Context, run *scrapeRun, robotsURL *url.URL) (*robots.Group, error) {
+	release, err := s.acquireHost(ctx, run, robotsURL)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	startTime := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.UserAgent != "" {
+		req.Header.Set("User-Agent", s.UserAgent)
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// https://www.rfc-editor.org/rfc/rfc9309.html#name-unreachable-status
+		log.Printf("%s unreachable, assuming complete disallow: %v", robotsURL.String(), err)
+		return robots.DisallowAll(), nil
+	}
+	data, err := s.storeResponse(resp, startTime, true, run)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Printf("%s unreachable, assuming complete disallow: %v", robotsURL.String(), err)
+		return robots.DisallowAll(), nil
+	}
+	switch {
+	case 200 <= resp.StatusCode && resp.StatusCode <= 299:
+		r := robots.Parse(data)
+		if s.DiscoverSitemaps {
+			sitemaps := s.followSitemaps(robotsURL, r.Sitemaps, 0, run)
+			if err := s.storeSitemapLinks(robotsURL, sitemaps); err != nil {
+				log.Printf("storing links of %s: %v", robotsURL.String(), err)
+			}
+		}
+		return r.Group(s.UserAgent), nil
+	case 400 <= resp.StatusCode && resp.StatusCode <= 499:
+		// https://www.rfc-editor.org/rfc/rfc9309.html#name-unavailable-status
+		return robots.AllowAll(), nil
+	default:
+		log.Printf("%s returned %s, assuming complete disallow", robotsURL.String(), resp.Status)
+		return robots.DisallowAll(), nil
+	}
+}
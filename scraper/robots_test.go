@@ -0,0 +1,66 @@
+package scraper
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/martin-sucha/site-to-static/repository"
+)
+
+func TestScraper_RobotsForUnreachable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scraper-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		// The connection is closed before the whole body is sent.
+		w.Header().Set("Content-Length", "100")
+		_, _ = w.Write([]byte("User-agent: *\n"))
+	}))
+	defer server.Close()
+
+	s := &Scraper{
+		Client:     *server.Client(),
+		Repository: repository.New(dir),
+	}
+	run := &scrapeRun{
+		robots: robotsCache{hosts: make(map[string]*hostRobots)},
+		hosts:  hostStates{hosts: make(map[string]*hostState)},
+	}
+	u, err := url.Parse(server.URL + "/page")
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		hr, err := s.robotsFor(context.Background(), run, u)
+		require.NoError(t, err)
+		assert.False(t, hr.group.Allowed(u))
+	}
+	assert.Equal(t, int64(1), atomic.LoadInt64(&requests), "robots.txt should be fetched only once")
+}
+
+func TestScraper_RobotsForCancelled(t *testing.T) {
+	s := &Scraper{}
+	run := &scrapeRun{
+		robots: robotsCache{hosts: make(map[string]*hostRobots)},
+		hosts:  hostStates{hosts: make(map[string]*hostState)},
+	}
+	u, err := url.Parse("http://example.com/page")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = s.robotsFor(ctx, run, u)
+	assert.Equal(t, context.Canceled, err)
+	assert.Empty(t, run.robots.hosts, "errors should not be cached")
+}
@@ -3,6 +3,7 @@ package scraper
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
@@ -11,10 +12,12 @@ import (
 	"mime"
 	"net/http"
 	"net/url"
+	"os"
 	"runtime/pprof"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/martin-sucha/site-to-static/repository"
@@ -27,13 +30,89 @@ import (
 type Scraper struct {
 	Client     http.Client
 	Repository *repository.Repository
-	Limiter    *rate.Limiter
+	// Limiter limits the rate of all requests. nil means no global limit.
+	Limiter *rate.Limiter
+	// DefaultHostLimit limits requests to hosts that are not listed in HostLimits.
+	DefaultHostLimit HostLimit
+	// HostLimits limits requests to individual hosts.
+	// The map key is host in canonical form, either with or without port.
+	HostLimits map[string]HostLimit
 	// FollowURL determines whether to scrape u or not.
 	FollowURL func(u *url.URL) bool
-	UserAgent string
+	// FollowResponse determines whether to store a response of u with the media type and scrape links from it.
+	// It is called only for URLs allowed by FollowURL, but not for redirects and sitemaps.
+	// nil allows all responses.
+	FollowResponse func(u *url.URL, mediatype string) bool
+	UserAgent      string
+	// Resume continues the scrape from the frontier stored in Repository.
+	// Documents that are already stored in Repository are not downloaded again, only links are extracted from them.
+	Resume bool
+	// CheckpointInterval is how often the frontier is stored to Repository.
+	// Zero means defaultCheckpointInterval.
+	CheckpointInterval time.Duration
+	// Incremental re-downloads documents already stored in Repository using conditional requests.
+	// Documents that were not modified are kept as they are, but links are still extracted from them.
+	// When combined with Resume, only documents stored before the resumed scrape started are re-downloaded.
+	Incremental bool
+	// Sitemaps are URLs of sitemaps to seed the scrape with.
+	// Pages listed in the sitemaps are scraped if FollowURL allows them.
+	Sitemaps []*url.URL
+	// DiscoverSitemaps scrapes also sitemaps listed in robots.txt files.
+	// It can't be combined with IgnoreRobots.
+	DiscoverSitemaps bool
+	// Retry configures retrying of requests that failed because of transient errors.
+	Retry RetryPolicy
+	// IgnoreRobots disables fetching robots.txt.
+	// Otherwise URLs disallowed by robots.txt for UserAgent are not downloaded and Crawl-delay is honoured.
+	IgnoreRobots bool
+	// MaxDepth is the maximum number of links to follow from initial URLs and sitemaps.
+	// Pages listed in a sitemap and redirect targets have the same depth as the sitemap or redirecting page.
+	// Zero means no limit.
+	MaxDepth int
+	// MaxPages is the maximum number of URLs to download in a single Scrape call.
+	// Zero means no limit.
+	MaxPages int64
+	// MaxBytes is the maximum number of body bytes to download in a single Scrape call.
+	// Zero means no limit.
+	MaxBytes int64
+	// RewriteOptions configure discovery of links in documents. nil means defaults.
+	RewriteOptions *rewrite.Options
 }
 
-func (s *Scraper) Scrape(initialURLs []*url.URL, workerCount int) {
+const defaultCheckpointInterval = 30 * time.Second
+
+// scrapeRun holds the state of a single Scrape call.
+type scrapeRun struct {
+	newTasks, doneTasks chan<- *task
+	// startedTime is the time when the scrape started.
+	// If the scrape was resumed, it is the time when the original scrape started.
+	startedTime time.Time
+
+	robots robotsCache
+	hosts  hostStates
+
+	failedTasks        int64
+	changedDocuments   int64
+	unchangedDocuments int64
+	robotsDisallowed   int64
+	excludedResponses  int64
+	depthLimited       int64
+	downloadedPages    int64
+	downloadedBytes    int64
+
+	// limitStop is closed when MaxPages or MaxBytes is reached.
+	limitStop   chan struct{}
+	limitOnce   sync.Once
+	limitReason string
+}
+
+// Scrape downloads initialURLs and all URLs they link to that FollowURL allows.
+// When ctx is cancelled, no new downloads are started and downloads in progress are discarded.
+// The frontier is stored in Repository so that the scrape can be resumed later.
+func (s *Scraper) Scrape(ctx context.Context, initialURLs []*url.URL, workerCount int) error {
+	if s.DiscoverSitemaps && s.IgnoreRobots {
+		return errors.New("sitemaps can't be discovered in robots.txt when robots.txt is ignored")
+	}
 	inTasks := make(chan *task)
 	doneTasks := make(chan *task)
 	outTasks := make(chan *task)
@@ -44,11 +123,81 @@ func (s *Scraper) Scrape(initialURLs []*url.URL, workerCount int) {
 			key:         repository.Key(u),
 		})
 	}
+	for _, u := range s.Sitemaps {
+		initialTasks = append(initialTasks, &task{
+			downloadURL: u,
+			key:         repository.Key(u),
+			sitemap:     true,
+		})
+	}
+	run := &scrapeRun{
+		newTasks:    inTasks,
+		doneTasks:   doneTasks,
+		startedTime: time.Now(),
+		robots: robotsCache{
+			hosts: make(map[string]*hostRobots),
+		},
+		hosts: hostStates{
+			hosts: make(map[string]*hostState),
+		},
+		limitStop: make(chan struct{}),
+	}
+	var seenKeys []string
+	if s.Resume {
+		frontier, err := s.Repository.LoadFrontier()
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			// Nothing to resume, start from initialURLs.
+		case err != nil:
+			return fmt.Errorf("load frontier: %v", err)
+		default:
+			for _, ft := range frontier.Pending {
+				u, err := url.Parse(ft.URL)
+				if err != nil {
+					return fmt.Errorf("load frontier: parse url %q: %v", ft.URL, err)
+				}
+				initialTasks = append(initialTasks, &task{
+					downloadURL: u,
+					key:         ft.Key,
+					sitemap:     ft.Sitemap,
+					depth:       ft.Depth,
+					referrer:    ft.Referrer,
+				})
+			}
+			seenKeys = frontier.Seen
+			if !frontier.StartedTime.IsZero() {
+				run.startedTime = frontier.StartedTime
+			}
+		}
+	}
+	checkpointInterval := s.CheckpointInterval
+	if checkpointInterval == 0 {
+		checkpointInterval = defaultCheckpointInterval
+	}
+	checkpointTicker := time.NewTicker(checkpointInterval)
+	defer checkpointTicker.Stop()
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-run.limitStop:
+		case <-finished:
+		}
+		close(stop)
+	}()
+	opts := queueOptions{
+		seenKeys:       seenKeys,
+		checkpointTick: checkpointTicker.C,
+		checkpoint:     run.saveFrontierFunc(s.Repository),
+		stop:           stop,
+	}
 	go func() {
 		defer close(inTasks)
 		defer close(doneTasks)
 		defer close(outTasks)
-		queue(initialTasks, inTasks, doneTasks, outTasks)
+		queue(initialTasks, inTasks, doneTasks, outTasks, opts)
 	}()
 
 	var wg sync.WaitGroup
@@ -56,34 +205,157 @@ func (s *Scraper) Scrape(initialURLs []*url.URL, workerCount int) {
 	for i := 0; i < workerCount; i++ {
 		wg.Add(1)
 		labels := pprof.Labels("scraper-worker", strconv.Itoa(i))
-		go pprof.Do(context.Background(), labels, func(_ context.Context) {
+		go pprof.Do(ctx, labels, func(ctx context.Context) {
 			defer wg.Done()
 			for t := range outTasks {
-				err := s.scrapeTask(t, inTasks, doneTasks)
-				if err != nil {
-					log.Println(err)
+				err := s.scrapeTask(ctx, run, t)
+				if err != nil && ctx.Err() == nil {
+					if t.referrer != "" {
+						log.Printf("%v (linked from %s)", err, t.referrer)
+					} else {
+						log.Println(err)
+					}
+					atomic.AddInt64(&run.failedTasks, 1)
 				}
 			}
 		})
 	}
 
 	wg.Wait()
+	if run.robotsDisallowed > 0 {
+		log.Printf("%d urls disallowed by robots.txt", run.robotsDisallowed)
+	}
+	if s.Incremental {
+		log.Printf("incremental scrape: %d documents changed, %d unchanged",
+			run.changedDocuments, run.unchangedDocuments)
+	}
+	if run.excludedResponses > 0 {
+		log.Printf("%d responses excluded by their media type", run.excludedResponses)
+	}
+	if run.depthLimited > 0 {
+		log.Printf("%d links deeper than max depth %d were not followed", run.depthLimited, s.MaxDepth)
+	}
+	if run.limitReason != "" {
+		log.Printf("scrape stopped: %s reached after %d urls and %d bytes, "+
+			"pending urls were saved and can be downloaded using resume", run.limitReason,
+			run.downloadedPages, run.downloadedBytes)
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("scrape interrupted (%d urls failed): %w", run.failedTasks, err)
+	}
+	if run.failedTasks > 0 {
+		return fmt.Errorf("%d urls failed, see log for details", run.failedTasks)
+	}
+	return nil
+}
+
+func (run *scrapeRun) saveFrontierFunc(repo *repository.Repository) func(pending []*task, seenKeys []string) {
+	return func(pending []*task, seenKeys []string) {
+		frontier := &repository.Frontier{
+			StartedTime: run.startedTime,
+			Pending:     make([]repository.FrontierTask, 0, len(pending)),
+			Seen:        seenKeys,
+		}
+		for _, t := range pending {
+			frontier.Pending = append(frontier.Pending, repository.FrontierTask{
+				URL:      t.downloadURL.String(),
+				Key:      t.key,
+				Sitemap:  t.sitemap,
+				Depth:    t.depth,
+				Referrer: t.referrer,
+			})
+		}
+		err := repo.SaveFrontier(frontier)
+		if err != nil {
+			log.Printf("save frontier: %v", err)
+		}
+	}
 }
 
-func (s *Scraper) scrapeTask(t *task, newTasks, doneTasks chan<- *task) (errOut error) {
+func (s *Scraper) scrapeTask(ctx context.Context, run *scrapeRun, t *task) (errOut error) {
 	defer func() {
-		doneTasks <- t
+		if errOut != nil && ctx.Err() != nil {
+			t.interrupted = true
+		}
+		run.doneTasks <- t
 	}()
-	err := s.Limiter.Wait(context.TODO())
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var previous *repository.DocumentMetadata
+	if s.Resume || s.Incremental {
+		doc, err := s.Repository.Load(t.key)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			// Not downloaded yet.
+		case err != nil:
+			return err
+		case s.Resume && (!s.Incremental || !doc.Metadata.DownloadStartedTime.Before(run.startedTime)):
+			// Already downloaded in this scrape.
+			return s.processStoredDocument(doc, run, t)
+		default:
+			previous = &doc.Metadata
+			err = doc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if !s.IgnoreRobots {
+		hr, err := s.robotsFor(ctx, run, t.downloadURL)
+		if err != nil {
+			return err
+		}
+		if !hr.group.Allowed(t.downloadURL) {
+			atomic.AddInt64(&run.robotsDisallowed, 1)
+			return nil
+		}
+	}
+	if !s.reservePage(run) {
+		// Keep the task pending so that it can be resumed.
+		t.interrupted = true
+		return nil
+	}
+	for attempt := 1; ; attempt++ {
+		lastAttempt := attempt >= s.Retry.MaxAttempts
+		err := s.fetchTask(ctx, run, t, previous, lastAttempt)
+		var retryErr *retryableError
+		if lastAttempt || !errors.As(err, &retryErr) {
+			var fetchErr *fetchError
+			if errors.As(err, &fetchErr) && ctx.Err() == nil {
+				s.storeFailure(t, err, attempt)
+			}
+			return err
+		}
+		timer := time.NewTimer(s.Retry.delay(attempt, retryErr.retryAfter))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// fetchTask downloads the task URL once and processes the response.
+// If the attempt failed because of a transient error and it is not the last attempt, *retryableError is returned
+// and the response is not stored.
+func (s *Scraper) fetchTask(ctx context.Context, run *scrapeRun, t *task, previous *repository.DocumentMetadata,
+	lastAttempt bool) error {
+	release, err := s.acquireHost(ctx, run, t.downloadURL)
 	if err != nil {
 		return err
 	}
+	defer release()
 	startTime := time.Now()
 	client := s.Client
 	originalCheckRedirect := client.CheckRedirect
 	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		// Validators of the previous document don't apply to the redirect target.
+		req.Header.Del("If-None-Match")
+		req.Header.Del("If-Modified-Since")
 		if req.Response != nil {
-			err := s.processResponse(req.Response, startTime, newTasks)
+			err := s.processResponse(req.Response, startTime, run, t, true)
 			if err != nil {
 				return err
 			}
@@ -96,63 +368,285 @@ func (s *Scraper) scrapeTask(t *task, newTasks, doneTasks chan<- *task) (errOut
 		}
 		return originalCheckRedirect(req, via)
 	}
-	req, err := http.NewRequest(http.MethodGet, t.downloadURL.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.downloadURL.String(), nil)
 	if err != nil {
 		return err
 	}
 	if s.UserAgent != "" {
 		req.Header.Set("User-Agent", s.UserAgent)
 	}
+	conditional := previous != nil && setConditionalHeaders(req.Header, previous)
 	resp, err := client.Do(req)
+	if err != nil {
+		err = &fetchError{err: err}
+		if ctx.Err() == nil && isRetryableError(err) {
+			return &retryableError{err: err}
+		}
+		return err
+	}
+	if conditional && resp.StatusCode == http.StatusNotModified {
+		return s.processNotModified(resp, startTime, run, t)
+	}
+	if !lastAttempt && isRetryableStatus(resp.StatusCode) {
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if s.Retry.acceptsRetryAfter(retryAfter) {
+			err = discardResponse(resp)
+			if err != nil {
+				return err
+			}
+			return &retryableError{
+				err:        fmt.Errorf("%s: %s", resp.Request.URL.String(), resp.Status),
+				retryAfter: retryAfter,
+			}
+		}
+	}
+	err = s.processResponse(resp, startTime, run, t, false)
+	if err != nil && ctx.Err() == nil && isRetryableError(err) {
+		return &retryableError{err: err}
+	}
+	return err
+}
+
+// fetchError is an error returned from http.Client or from reading the response body,
+// no complete response was received.
+type fetchError struct {
+	err error
+}
+
+func (e *fetchError) Error() string {
+	return e.err.Error()
+}
+
+func (e *fetchError) Unwrap() error {
+	return e.err
+}
+
+// storeFailure records in the repository that the task could not be fetched.
+func (s *Scraper) storeFailure(t *task, err error, attempts int) {
+	storeErr := s.Repository.StoreFailure(&repository.Failure{
+		Key:      t.key,
+		URL:      t.downloadURL.String(),
+		Referrer: t.referrer,
+		Error:    err.Error(),
+		Time:     time.Now(),
+		Attempts: attempts,
+	})
+	if storeErr != nil {
+		log.Printf("store failure of %q: %v", t.downloadURL.String(), storeErr)
+	}
+}
+
+// discardResponse reads the rest of the response body and closes it.
+func discardResponse(resp *http.Response) error {
+	_, err := io.Copy(ioutil.Discard, resp.Body)
+	closeErr := resp.Body.Close()
 	if err != nil {
 		return err
 	}
-	return s.processResponse(resp, startTime, newTasks)
+	return closeErr
 }
 
-func (s *Scraper) processResponse(resp *http.Response, startTime time.Time, newTasks chan<- *task) error {
+// setConditionalHeaders sets headers to make the request conditional on validators of the previous response.
+// Returns whether the request is conditional.
+func setConditionalHeaders(header http.Header, previous *repository.DocumentMetadata) bool {
+	if previous.StatusCode != http.StatusOK {
+		return false
+	}
+	conditional := false
+	if etag := previous.Headers.Get("ETag"); etag != "" {
+		header.Set("If-None-Match", etag)
+		conditional = true
+	}
+	if lastModified := previous.Headers.Get("Last-Modified"); lastModified != "" {
+		header.Set("If-Modified-Since", lastModified)
+		conditional = true
+	}
+	return conditional
+}
+
+// processNotModified keeps the previously stored document and extracts links from it.
+// The download time of the document is updated to startTime, so that a resumed scrape doesn't request it again.
+func (s *Scraper) processNotModified(resp *http.Response, startTime time.Time, run *scrapeRun, t *task) error {
+	err := discardResponse(resp)
+	if err != nil {
+		return err
+	}
+	atomic.AddInt64(&run.unchangedDocuments, 1)
+	err = s.Repository.UpdateMetadata(t.key, func(metadata *repository.DocumentMetadata) {
+		metadata.DownloadStartedTime = startTime
+	})
+	if err != nil {
+		return err
+	}
+	doc, err := s.Repository.Load(t.key)
+	if err != nil {
+		return err
+	}
+	return s.processStoredDocument(doc, run, t)
+}
+
+// processResponse stores the response and discovers links in it.
+// redirectFollowed is true if the response is a redirect that the HTTP client follows.
+func (s *Scraper) processResponse(resp *http.Response, startTime time.Time, run *scrapeRun, t *task,
+	redirectFollowed bool) error {
+	isSitemap := t.sitemap && resp.StatusCode == http.StatusOK
 	supportedContentType := false
 	mediatype, params, err := mime.ParseMediaType(resp.Header.Get("content-type"))
 	if err == nil {
 		supportedContentType = rewrite.IsSupportedMediaType(mediatype, params)
 	}
-	data, err := s.storeResponse(resp, startTime, supportedContentType)
+	isRedirect := 300 <= resp.StatusCode && resp.StatusCode <= 399
+	if !t.sitemap && !isRedirect && s.FollowResponse != nil && !s.FollowResponse(resp.Request.URL, mediatype) {
+		atomic.AddInt64(&run.excludedResponses, 1)
+		return discardResponse(resp)
+	}
+	data, err := s.storeResponse(resp, startTime, supportedContentType || isSitemap, run)
 	if err != nil {
 		return err
 	}
-	if !supportedContentType {
-		return nil
+	if isSitemap {
+		return s.discoverSitemapLinks(resp.Request.URL, t.depth, data, run)
 	}
-	rewriter := func(u rewrite.URL) (string, error) {
-		referenceURL, err := url.Parse(strings.TrimSpace(u.Value))
+	return s.discoverLinks(resp.Request.URL, t.depth, resp.Header, !redirectFollowed, data, run)
+}
+
+// processStoredDocument discovers links in a document that was already stored in the repository.
+func (s *Scraper) processStoredDocument(doc *repository.Document, run *scrapeRun, t *task) (errOut error) {
+	defer func() {
+		closeErr := doc.Close()
+		if errOut == nil {
+			errOut = closeErr
+		}
+	}()
+	docURL, err := url.Parse(doc.Metadata.URL)
+	if err != nil {
+		return err
+	}
+	if t.sitemap && doc.Metadata.StatusCode == http.StatusOK {
+		data, err := ioutil.ReadAll(doc.Body())
 		if err != nil {
-			log.Printf("parsing url in document %q: %v", resp.Request.URL.String(), err)
-			return "", nil
+			return err
+		}
+		return s.discoverSitemapLinks(docURL, t.depth, data, run)
+	}
+	var data []byte
+	mediatype, params, err := mime.ParseMediaType(doc.Metadata.Headers.Get("content-type"))
+	if err == nil && rewrite.IsSupportedMediaType(mediatype, params) {
+		data, err = ioutil.ReadAll(doc.Body())
+		if err != nil {
+			return err
+		}
+	}
+	// The redirect target was fetched by the HTTP client, but the previous run might have been interrupted
+	// before storing it, so Location is followed.
+	return s.discoverLinks(docURL, t.depth, doc.Metadata.Headers, true, data, run)
+}
+
+// discoverLinks adds new tasks for links in response headers and document data downloaded from docURL
+// and stores the links in the repository.
+// depth is the link depth of the document.
+// data is the document body, it is nil if the document media type is not supported by rewrite.
+// If followLocation is false, the Location header is only stored as a link.
+func (s *Scraper) discoverLinks(docURL *url.URL, depth int, header http.Header, followLocation bool, data []byte,
+	run *scrapeRun) error {
+	links := &repository.Links{
+		SourceKey: repository.Key(docURL),
+		SourceURL: docURL.String(),
+	}
+	rewriter := func(u rewrite.URL) (string, error) {
+		if !u.IsFetchable() {
+			return "", rewrite.ErrNotModified
 		}
-		baseURL := resp.Request.URL
+		baseURL := docURL
 		if u.Base != "" {
+			var err error
 			baseURL, err = url.Parse(u.Base)
 			if err != nil {
-				return "", fmt.Errorf("parsing base url in document %q: %v", resp.Request.URL.String(), err)
+				return "", fmt.Errorf("parsing base url in document %q: %v", docURL.String(), err)
 			}
 		}
-		absoluteURL := baseURL.ResolveReference(referenceURL)
-		if s.FollowURL == nil || !s.FollowURL(absoluteURL) {
-			return "", rewrite.ErrNotModified
+		// Redirect targets and alternative locations of the document keep its depth.
+		var targetURL *url.URL
+		switch u.Type {
+		case rewrite.URLTypeHTTPLocation:
+			if followLocation {
+				targetURL = s.followURL(baseURL, u.Value, docURL, depth, run)
+			} else {
+				targetURL = resolveURL(baseURL, u.Value)
+			}
+		case rewrite.URLTypeHTTPContentLocation:
+			targetURL = s.followURL(baseURL, u.Value, docURL, depth, run)
+		default:
+			targetURL = s.followURL(baseURL, u.Value, docURL, depth+1, run)
 		}
-		key := repository.Key(absoluteURL)
-		newTasks <- &task{
-			downloadURL: absoluteURL,
-			key:         key,
+		if targetURL != nil && (targetURL.Scheme == "http" || targetURL.Scheme == "https") {
+			links.Links = append(links.Links, repository.Link{
+				TargetKey: repository.Key(targetURL),
+				TargetURL: targetURL.String(),
+				Type:      u.Type.String(),
+				Tag:       u.Tag,
+				Attribute: u.Attribute,
+			})
 		}
 		return "", rewrite.ErrNotModified
 	}
 
-	return rewrite.Document(mediatype, params, parse.NewInputBytes(data), ioutil.Discard, rewriter)
+	_, err := rewrite.Headers(header, rewriter)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		mediatype, params, err := mime.ParseMediaType(header.Get("content-type"))
+		if err != nil {
+			return err
+		}
+		err = rewrite.Document(mediatype, params, parse.NewInputBytes(data), ioutil.Discard, rewriter, s.RewriteOptions)
+		if err != nil {
+			return err
+		}
+	} else if len(links.Links) == 0 {
+		return nil
+	}
+	return s.Repository.StoreLinks(links)
+}
+
+// followURL adds a new task for reference resolved against baseURL if FollowURL and MaxDepth allow it.
+// referrer is the URL of the document containing the reference, depth is the link depth of the new task.
+// Returns the resolved URL, or nil if reference could not be parsed.
+func (s *Scraper) followURL(baseURL *url.URL, reference string, referrer *url.URL, depth int,
+	run *scrapeRun) *url.URL {
+	absoluteURL := resolveURL(baseURL, reference)
+	if absoluteURL == nil {
+		return nil
+	}
+	if s.FollowURL == nil || !s.FollowURL(absoluteURL) {
+		return absoluteURL
+	}
+	if s.MaxDepth > 0 && depth > s.MaxDepth {
+		atomic.AddInt64(&run.depthLimited, 1)
+		return absoluteURL
+	}
+	run.newTasks <- &task{
+		downloadURL: absoluteURL,
+		key:         repository.Key(absoluteURL),
+		depth:       depth,
+		referrer:    referrer.String(),
+	}
+	return absoluteURL
+}
+
+// resolveURL returns reference resolved against baseURL, or nil if reference could not be parsed.
+func resolveURL(baseURL *url.URL, reference string) *url.URL {
+	referenceURL, err := url.Parse(strings.TrimSpace(reference))
+	if err != nil {
+		log.Printf("parsing url in document %q: %v", baseURL.String(), err)
+		return nil
+	}
+	return baseURL.ResolveReference(referenceURL)
 }
 
 func (s *Scraper) storeResponse(resp *http.Response, startTime time.Time,
-	loadToMemory bool) (dataOut []byte, errOut error) {
+	loadToMemory bool, run *scrapeRun) (dataOut []byte, errOut error) {
 	defer func() {
 		closeErr := resp.Body.Close()
 		if errOut == nil {
@@ -161,7 +655,7 @@ func (s *Scraper) storeResponse(resp *http.Response, startTime time.Time,
 	}()
 
 	var buf bytes.Buffer
-	var bodyReader io.Reader = resp.Body
+	var bodyReader io.Reader = bodyErrorReader{r: resp.Body}
 	if loadToMemory {
 		bodyReader = io.TeeReader(bodyReader, &buf)
 	}
@@ -169,7 +663,16 @@ func (s *Scraper) storeResponse(resp *http.Response, startTime time.Time,
 	if err != nil {
 		return nil, err
 	}
-	_, err = io.Copy(dw, bodyReader)
+	n, err := io.Copy(dw, bodyReader)
+	s.addDownloadedBytes(run, n)
+	if err != nil {
+		// Don't store incomplete documents.
+		discardErr := dw.Discard()
+		if discardErr != nil {
+			log.Printf("discard incomplete document: %v", discardErr)
+		}
+		return nil, err
+	}
 	meta := &repository.DocumentMetadata{
 		Key:                 repository.Key(resp.Request.URL),
 		DownloadStartedTime: startTime,
@@ -180,9 +683,44 @@ func (s *Scraper) storeResponse(resp *http.Response, startTime time.Time,
 		Status:              resp.Status,
 		StatusCode:          resp.StatusCode,
 	}
-	closeErr := dw.Close(meta)
+	if s.Incremental {
+		s.countChange(meta, dw.BodySHA256(), run)
+	}
+	err = dw.Close(meta)
 	if err != nil {
 		return nil, err
 	}
-	return buf.Bytes(), closeErr
+	return buf.Bytes(), nil
+}
+
+// bodyErrorReader wraps errors from reading the response body in *fetchError,
+// so that they can be told apart from errors writing to the repository.
+type bodyErrorReader struct {
+	r io.Reader
+}
+
+func (r bodyErrorReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = &fetchError{err: err}
+	}
+	return n, err
+}
+
+// countChange compares the document about to be stored with the one already in the repository.
+func (s *Scraper) countChange(meta *repository.DocumentMetadata, bodySHA256 [sha256.Size]byte, run *scrapeRun) {
+	changed := true
+	previous, err := s.Repository.Load(meta.Key)
+	if err == nil {
+		changed = previous.Metadata.StatusCode != meta.StatusCode || previous.BodySHA256 != bodySHA256
+		err = previous.Close()
+		if err != nil {
+			log.Printf("close %q: %v", meta.Key, err)
+		}
+	}
+	if changed {
+		atomic.AddInt64(&run.changedDocuments, 1)
+	} else {
+		atomic.AddInt64(&run.unchangedDocuments, 1)
+	}
 }
@@ -0,0 +1,67 @@
+package scraper
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/martin-sucha/site-to-static/repository"
+)
+
+func TestScraper_DiscoverHeaderLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scraper-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	newTasks := make(chan *task, 10)
+	s := &Scraper{
+		Repository: repository.New(dir),
+		FollowURL: func(u *url.URL) bool {
+			return true
+		},
+	}
+	run := &scrapeRun{newTasks: newTasks}
+	docURL, err := url.Parse("http://example.com/a/")
+	require.NoError(t, err)
+	header := http.Header{
+		"Link":             {`</style.css>; rel=preload; as=style, <b.js>; rel="modulepreload"`},
+		"Refresh":          {"5; url=/refreshed"},
+		"Content-Location": {"/a/index.html"},
+		"Location":         {"/moved"},
+		"Content-Type":     {"image/png"},
+	}
+
+	err = s.discoverLinks(docURL, 1, header, false, nil, run)
+	require.NoError(t, err)
+	close(newTasks)
+
+	depths := make(map[string]int)
+	for nt := range newTasks {
+		depths[nt.downloadURL.String()] = nt.depth
+	}
+	assert.Equal(t, map[string]int{
+		"http://example.com/style.css":    2,
+		"http://example.com/a/b.js":       2,
+		"http://example.com/refreshed":    2,
+		"http://example.com/a/index.html": 1,
+	}, depths)
+
+	links, err := s.Repository.LoadLinks(repository.Key(docURL))
+	require.NoError(t, err)
+	types := make(map[string]string)
+	for _, link := range links.Links {
+		types[link.TargetURL] = link.Type
+	}
+	assert.Equal(t, map[string]string{
+		"http://example.com/style.css":    "http-link",
+		"http://example.com/a/b.js":       "http-link",
+		"http://example.com/refreshed":    "http-refresh",
+		"http://example.com/a/index.html": "http-content-location",
+		"http://example.com/moved":        "http-location",
+	}, types)
+}
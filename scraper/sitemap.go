@@ -0,0 +1,99 @@
+package scraper
+
+import (
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/martin-sucha/site-to-static/repository"
+	"github.com/martin-sucha/site-to-static/sitemap"
+	"github.com/martin-sucha/site-to-static/urlnorm"
+)
+
+// discoverSitemapLinks adds new tasks for pages and sitemaps listed in sitemap data downloaded from sitemapURL
+// and stores the listed URLs as links of the sitemap.
+// The new tasks have the same depth as the sitemap.
+func (s *Scraper) discoverSitemapLinks(sitemapURL *url.URL, depth int, data []byte, run *scrapeRun) error {
+	sm, err := sitemap.Parse(data)
+	if err != nil {
+		return err
+	}
+	targets := make([]*url.URL, 0, len(sm.URLs)+len(sm.Sitemaps))
+	for _, loc := range sm.URLs {
+		targetURL := s.followURL(sitemapURL, loc, sitemapURL, depth, run)
+		if targetURL != nil {
+			targets = append(targets, targetURL)
+		}
+	}
+	targets = append(targets, s.followSitemaps(sitemapURL, sm.Sitemaps, depth, run)...)
+	return s.storeSitemapLinks(sitemapURL, targets)
+}
+
+// storeSitemapLinks stores http and https targets listed in a sitemap or robots.txt downloaded from docURL
+// as links of type repository.LinkTypeSitemap.
+func (s *Scraper) storeSitemapLinks(docURL *url.URL, targets []*url.URL) error {
+	links := &repository.Links{
+		SourceKey: repository.Key(docURL),
+		SourceURL: docURL.String(),
+	}
+	for _, targetURL := range targets {
+		if targetURL.Scheme != "http" && targetURL.Scheme != "https" {
+			continue
+		}
+		links.Links = append(links.Links, repository.Link{
+			TargetKey: repository.Key(targetURL),
+			TargetURL: targetURL.String(),
+			Type:      repository.LinkTypeSitemap,
+		})
+	}
+	return s.Repository.StoreLinks(links)
+}
+
+// followSitemaps adds new tasks to scrape sitemaps listed in a document downloaded from baseURL.
+// Sitemaps are scraped even if FollowURL does not allow them, FollowURL is applied to the pages they list.
+// Sitemaps on other hosts than baseURL and the seed sitemaps are scraped only if FollowURL allows them.
+// Returns the URLs of sitemaps that were added.
+func (s *Scraper) followSitemaps(baseURL *url.URL, locations []string, depth int, run *scrapeRun) []*url.URL {
+	var followed []*url.URL
+	for _, loc := range locations {
+		referenceURL, err := url.Parse(strings.TrimSpace(loc))
+		if err != nil {
+			log.Printf("parsing sitemap url in document %q: %v", baseURL.String(), err)
+			continue
+		}
+		absoluteURL := baseURL.ResolveReference(referenceURL)
+		if !s.sitemapAllowed(baseURL, absoluteURL) {
+			log.Printf("sitemap %s listed in %s is not allowed, skipping", absoluteURL.String(), baseURL.String())
+			continue
+		}
+		run.newTasks <- &task{
+			downloadURL: absoluteURL,
+			key:         repository.Key(absoluteURL),
+			sitemap:     true,
+			depth:       depth,
+			referrer:    baseURL.String(),
+		}
+		followed = append(followed, absoluteURL)
+	}
+	return followed
+}
+
+// sitemapAllowed returns whether sitemapURL listed in a document downloaded from baseURL may be scraped.
+func (s *Scraper) sitemapAllowed(baseURL, sitemapURL *url.URL) bool {
+	if sitemapURL.Scheme != "http" && sitemapURL.Scheme != "https" {
+		return false
+	}
+	if s.FollowURL != nil && s.FollowURL(sitemapURL) {
+		return true
+	}
+	host := urlnorm.Canonical(sitemapURL).Host
+	if host == urlnorm.Canonical(baseURL).Host {
+		return true
+	}
+	for _, seed := range s.Sitemaps {
+		if host == urlnorm.Canonical(seed).Host {
+			return true
+		}
+	}
+	return false
+}
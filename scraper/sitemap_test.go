@@ -0,0 +1,145 @@
+package scraper
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/martin-sucha/site-to-static/repository"
+)
+
+func TestScraper_FollowSitemaps(t *testing.T) {
+	seed, err := url.Parse("https://seed.example.com/sitemap.xml")
+	require.NoError(t, err)
+	s := &Scraper{
+		FollowURL: func(u *url.URL) bool {
+			return strings.HasPrefix(u.String(), "http://allowed.example.com/")
+		},
+		Sitemaps: []*url.URL{seed},
+	}
+	newTasks := make(chan *task, 10)
+	run := &scrapeRun{newTasks: newTasks}
+	baseURL, err := url.Parse("http://example.com/robots.txt")
+	require.NoError(t, err)
+
+	s.followSitemaps(baseURL, []string{
+		"/sitemap.xml",
+		"http://EXAMPLE.com:80/news.xml",
+		"http://allowed.example.com/sitemap.xml",
+		"https://seed.example.com/other.xml",
+		"http://third-party.example.net/sitemap.xml",
+		"ftp://example.com/sitemap.xml",
+	}, 2, run)
+	close(newTasks)
+
+	var urls []string
+	for nt := range newTasks {
+		assert.True(t, nt.sitemap)
+		assert.Equal(t, 2, nt.depth)
+		assert.Equal(t, "http://example.com/robots.txt", nt.referrer)
+		urls = append(urls, nt.downloadURL.String())
+	}
+	assert.Equal(t, []string{
+		"http://example.com/sitemap.xml",
+		"http://EXAMPLE.com:80/news.xml",
+		"http://allowed.example.com/sitemap.xml",
+		"https://seed.example.com/other.xml",
+	}, urls)
+}
+
+func TestScraper_DiscoverSitemapsIgnoreRobots(t *testing.T) {
+	s := &Scraper{DiscoverSitemaps: true, IgnoreRobots: true}
+	err := s.Scrape(context.Background(), nil, 1)
+	assert.Error(t, err)
+}
+
+func TestScraper_DiscoverSitemapLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scraper-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	repo := repository.New(dir)
+	s := &Scraper{
+		Repository: repo,
+		FollowURL: func(u *url.URL) bool {
+			return u.Path != "/out-of-scope"
+		},
+	}
+	newTasks := make(chan *task, 10)
+	run := &scrapeRun{newTasks: newTasks}
+	sitemapURL, err := url.Parse("http://example.com/sitemap.xml")
+	require.NoError(t, err)
+
+	err = s.discoverSitemapLinks(sitemapURL, 1, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>http://example.com/page</loc></url>
+<url><loc>http://example.com/out-of-scope</loc></url>
+<url><loc>mailto:a@example.com</loc></url>
+</urlset>`), run)
+	require.NoError(t, err)
+	close(newTasks)
+	var urls []string
+	for nt := range newTasks {
+		urls = append(urls, nt.downloadURL.String())
+	}
+	assert.Equal(t, []string{"http://example.com/page", "mailto:a@example.com"}, urls)
+
+	// Pages reached only through the sitemap can be traced back to it, including those not followed.
+	links, err := repo.LoadLinks(repository.Key(sitemapURL))
+	require.NoError(t, err)
+	assert.Equal(t, &repository.Links{
+		SourceKey: "http://example.com/sitemap.xml",
+		SourceURL: "http://example.com/sitemap.xml",
+		Links: []repository.Link{
+			{
+				TargetKey: "http://example.com/page",
+				TargetURL: "http://example.com/page",
+				Type:      repository.LinkTypeSitemap,
+			},
+			{
+				TargetKey: "http://example.com/out-of-scope",
+				TargetURL: "http://example.com/out-of-scope",
+				Type:      repository.LinkTypeSitemap,
+			},
+		},
+	}, links)
+}
+
+func TestScraper_DiscoverSitemapIndexLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scraper-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	repo := repository.New(dir)
+	s := &Scraper{Repository: repo}
+	newTasks := make(chan *task, 10)
+	run := &scrapeRun{newTasks: newTasks}
+	sitemapURL, err := url.Parse("http://example.com/sitemap.xml")
+	require.NoError(t, err)
+
+	err = s.discoverSitemapLinks(sitemapURL, 0, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<sitemap><loc>http://example.com/news.xml</loc></sitemap>
+<sitemap><loc>http://third-party.example.net/sitemap.xml</loc></sitemap>
+</sitemapindex>`), run)
+	require.NoError(t, err)
+	close(newTasks)
+
+	idx, err := repo.NewInboundIndex()
+	require.NoError(t, err)
+	assert.Equal(t, []*repository.Links{{
+		SourceKey: "http://example.com/sitemap.xml",
+		SourceURL: "http://example.com/sitemap.xml",
+		Links: []repository.Link{{
+			TargetKey: "http://example.com/news.xml",
+			TargetURL: "http://example.com/news.xml",
+			Type:      repository.LinkTypeSitemap,
+		}},
+	}}, idx.Inbound("http://example.com/news.xml"))
+	// Sitemaps that are not allowed are not recorded.
+	assert.Empty(t, idx.Inbound("http://third-party.example.net/sitemap.xml"))
+}
@@ -0,0 +1,110 @@
+// Package sitemap implements parsing of sitemap files.
+//
+// Sitemaps in XML format, sitemap index files and text sitemaps are supported, all of them optionally gzip-compressed.
+//
+// See https://www.sitemaps.org/protocol.html
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// maxUncompressedSize limits size of decompressed data.
+// The protocol allows at most 50MiB, we allow some more, but still protect against decompression bombs.
+const maxUncompressedSize = 100 << 20
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// Sitemap contains locations listed in a sitemap or a sitemap index file.
+type Sitemap struct {
+	// URLs are locations of pages listed in a sitemap.
+	URLs []string
+	// Sitemaps are locations of sitemaps listed in a sitemap index.
+	Sitemaps []string
+}
+
+// Parse parses sitemap data.
+// Data compressed with gzip are decompressed first.
+func Parse(data []byte) (*Sitemap, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("sitemap: gzip: %v", err)
+		}
+		data, err = ioutil.ReadAll(io.LimitReader(zr, maxUncompressedSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("sitemap: gzip: %v", err)
+		}
+		if len(data) > maxUncompressedSize {
+			return nil, fmt.Errorf("sitemap: uncompressed size exceeds %d bytes", maxUncompressedSize)
+		}
+	}
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, utf8BOM), " \t\r\n")
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		return parseText(trimmed)
+	}
+	return parseXML(trimmed)
+}
+
+// parseText parses a sitemap with one URL per line.
+func parseText(data []byte) (*Sitemap, error) {
+	sm := &Sitemap{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		sm.URLs = append(sm.URLs, line)
+	}
+	return sm, scanner.Err()
+}
+
+func parseXML(data []byte) (*Sitemap, error) {
+	sm := &Sitemap{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// stack contains local names of the currently open elements.
+	var stack []string
+	var loc strings.Builder
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return sm, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("sitemap: %v", err)
+		}
+		switch tok := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, tok.Name.Local)
+			loc.Reset()
+		case xml.CharData:
+			if len(stack) > 0 && stack[len(stack)-1] == "loc" {
+				loc.Write(tok)
+			}
+		case xml.EndElement:
+			if len(stack) >= 2 && stack[len(stack)-1] == "loc" {
+				value := strings.TrimSpace(loc.String())
+				if value != "" {
+					switch stack[len(stack)-2] {
+					case "url":
+						sm.URLs = append(sm.URLs, value)
+					case "sitemap":
+						sm.Sitemaps = append(sm.Sitemaps, value)
+					}
+				}
+			}
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output *Sitemap
+		err    string
+	}{
+		{
+			name: "urlset",
+			input: `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
+        xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+  <url>
+    <loc>https://example.com/</loc>
+    <lastmod>2005-01-01</lastmod>
+  </url>
+  <url>
+    <loc>
+      https://example.com/catalog?item=12&amp;desc=vacation_hawaii
+    </loc>
+    <image:image>
+      <image:loc>https://example.com/image.jpg</image:loc>
+    </image:image>
+  </url>
+</urlset>`,
+			output: &Sitemap{
+				URLs: []string{
+					"https://example.com/",
+					"https://example.com/catalog?item=12&desc=vacation_hawaii",
+				},
+			},
+		},
+		{
+			name: "sitemap index",
+			input: `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>https://example.com/sitemap1.xml.gz</loc>
+    <lastmod>2004-10-01T18:23:17+00:00</lastmod>
+  </sitemap>
+  <sitemap>
+    <loc>https://example.com/sitemap2.xml</loc>
+  </sitemap>
+</sitemapindex>`,
+			output: &Sitemap{
+				Sitemaps: []string{
+					"https://example.com/sitemap1.xml.gz",
+					"https://example.com/sitemap2.xml",
+				},
+			},
+		},
+		{
+			name:  "text",
+			input: "https://example.com/a.html\r\n\r\nhttps://example.com/b.html\n",
+			output: &Sitemap{
+				URLs: []string{
+					"https://example.com/a.html",
+					"https://example.com/b.html",
+				},
+			},
+		},
+		{
+			name:  "invalid xml",
+			input: "<urlset><url><loc>https://example.com/</url></urlset>",
+			err:   "sitemap: XML syntax error on line 1: element <loc> closed by </url>",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			output, err := Parse([]byte(test.input))
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.output, output)
+			}
+		})
+	}
+}
+
+func TestParse_Gzip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(`<urlset><url><loc>https://example.com/</loc></url></urlset>`))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	output, err := Parse(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, &Sitemap{URLs: []string{"https://example.com/"}}, output)
+}
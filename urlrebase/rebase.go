@@ -15,11 +15,95 @@ var ErrNoBase error = errors.New("base is not a parent of url")
 
 // Rebase rewrites URL to be under different base.
 // oldBase and newBase must be absolute URLs.
-func Rebase(u, oldBase, newBase *url.URL) (*url.URL, error) {
-	if !u.IsAbs() {
-		// TODO: support relative URLs
+//
+// If u is a relative reference, it is resolved against docBase, the base URL of the document containing it,
+// and the result is made relative to the rebased docBase in the same form as u, see SameForm.
+// docBase may be nil if u is absolute.
+func Rebase(u, docBase, oldBase, newBase *url.URL) (*url.URL, error) {
+	if u.IsAbs() {
+		return rebaseAbsolute(u, oldBase, newBase)
+	}
+	if docBase == nil {
 		return nil, ErrNoBase
 	}
+	rebased, err := rebaseAbsolute(docBase.ResolveReference(u), oldBase, newBase)
+	if err != nil {
+		return nil, err
+	}
+	// The document moves with the links if it is under oldBase.
+	newDocBase, err := rebaseAbsolute(docBase, oldBase, newBase)
+	switch {
+	case errors.Is(err, ErrNoBase):
+		newDocBase = urlnorm.Canonical(docBase)
+	case err != nil:
+		return nil, err
+	}
+	return SameForm(u, rebased, newDocBase), nil
+}
+
+// SameForm returns absolute URL target as a reference in the same form as ref, a relative reference
+// in a document at absolute URL docBase.
+//
+// Network-path references stay network-path references, root-relative references stay root-relative,
+// path-relative references become relative to docBase and references to the document itself keep only the query
+// and fragment. If target cannot be expressed in the form, because it has a different scheme or host than docBase,
+// target is returned.
+func SameForm(ref, target, docBase *url.URL) *url.URL {
+	if target.Scheme != docBase.Scheme {
+		return target
+	}
+	if ref.Host != "" {
+		u := *target
+		u.Scheme = ""
+		return &u
+	}
+	if target.Host != docBase.Host {
+		return target
+	}
+	relative := &url.URL{
+		Path:        target.Path,
+		RawQuery:    target.RawQuery,
+		Fragment:    target.Fragment,
+		RawFragment: target.RawFragment,
+	}
+	switch {
+	case strings.HasPrefix(ref.Path, "/"):
+	case ref.Path == "" && target.Path == docBase.Path:
+		// Reference to the document itself, like ?query or #fragment.
+		relative.Path = ""
+		if ref.RawQuery == "" && !ref.ForceQuery {
+			relative.RawQuery = ""
+		}
+	default:
+		relative.Path = RelativePath(docBase.Path, target.Path)
+	}
+	return relative
+}
+
+// RelativePath returns path-relative reference to path target from a document at path docPath.
+// Both paths are unescaped and slash-separated, so is the result.
+func RelativePath(docPath, target string) string {
+	dir := strings.Split(docPath[:strings.LastIndex(docPath, "/")+1], "/")
+	dir = dir[:len(dir)-1]
+	targetParts := strings.Split(target, "/")
+	common := 0
+	for common < len(dir) && common < len(targetParts)-1 && dir[common] == targetParts[common] {
+		common++
+	}
+	var parts []string
+	for i := common; i < len(dir); i++ {
+		parts = append(parts, "..")
+	}
+	parts = append(parts, targetParts[common:]...)
+	p := strings.Join(parts, "/")
+	if p == "" || strings.Contains(strings.SplitN(p, "/", 2)[0], ":") {
+		// Empty reference would point to the document itself, colon in the first segment would be a scheme.
+		p = "./" + p
+	}
+	return p
+}
+
+func rebaseAbsolute(u, oldBase, newBase *url.URL) (*url.URL, error) {
 	u = urlnorm.Canonical(u)
 	oldBase = urlnorm.Canonical(oldBase)
 	newBase = urlnorm.Canonical(newBase)
@@ -0,0 +1,95 @@
+package urlrebase
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParse(t *testing.T, s string) *url.URL {
+	u, err := url.Parse(s)
+	require.NoError(t, err)
+	return u
+}
+
+func TestRebase(t *testing.T) {
+	tests := []struct {
+		u, docBase, expected string
+	}{
+		{"http://example.com/old-app/page", "", "https://example.org/new/page"},
+		{"HTTP://Example.COM:80/old-app/", "", "https://example.org/new/"},
+		{"/old-app/page?q=1#f", "http://example.com/old-app/a/b", "/new/page?q=1#f"},
+		{"//example.com/old-app/page", "http://example.com/other", "https://example.org/new/page"},
+		{"/old-app/page", "http://example.com/other", "https://example.org/new/page"},
+		{"page", "http://example.com/old-app/a/b", "page"},
+		{"../c/d", "http://example.com/old-app/a/b/e", "../c/d"},
+		{"../../page", "http://example.com/old-app/a/b/e", "../../page"},
+		{"./", "http://example.com/old-app/a/b", "./"},
+		{".", "http://example.com/old-app/a/b", "./"},
+		{"./x:y", "http://example.com/old-app/a/b", "./x:y"},
+		{"?q=2", "http://example.com/old-app/a/b?q=1", "?q=2"},
+		{"#top", "http://example.com/old-app/a/b?q=1", "#top"},
+		{"old-app/page", "http://example.com/index.html", "https://example.org/new/page"},
+	}
+	oldBase := mustParse(t, "http://example.com/old-app/")
+	newBase := mustParse(t, "https://example.org/new/")
+	for _, test := range tests {
+		var docBase *url.URL
+		if test.docBase != "" {
+			docBase = mustParse(t, test.docBase)
+		}
+		rebased, err := Rebase(mustParse(t, test.u), docBase, oldBase, newBase)
+		if assert.NoError(t, err, test.u) {
+			assert.Equal(t, test.expected, rebased.String(), test.u)
+		}
+	}
+}
+
+func TestRebase_SameHost(t *testing.T) {
+	oldBase := mustParse(t, "http://example.com/old-app/")
+	newBase := mustParse(t, "http://example.com/new/app/")
+	docBase := mustParse(t, "http://example.com/old-app/a/b")
+	tests := []struct {
+		u, expected string
+	}{
+		{"/old-app/page", "/new/app/page"},
+		{"//example.com/old-app/page", "//example.com/new/app/page"},
+		{"../page", "../page"},
+		{"c", "c"},
+	}
+	for _, test := range tests {
+		rebased, err := Rebase(mustParse(t, test.u), docBase, oldBase, newBase)
+		if assert.NoError(t, err, test.u) {
+			assert.Equal(t, test.expected, rebased.String(), test.u)
+		}
+	}
+
+	// Links from documents outside of the old base point into the new one.
+	rebased, err := Rebase(mustParse(t, "old-app/page"), mustParse(t, "http://example.com/index.html"),
+		oldBase, newBase)
+	require.NoError(t, err)
+	assert.Equal(t, "new/app/page", rebased.String())
+}
+
+func TestRebase_NoBase(t *testing.T) {
+	oldBase := mustParse(t, "http://example.com/old-app/")
+	newBase := mustParse(t, "https://example.org/new/")
+	for _, test := range []struct {
+		u, docBase string
+	}{
+		{"http://example.com/other", ""},
+		{"https://example.com/old-app/page", ""},
+		{"/old-app/page", ""},
+		{"/other", "http://example.com/old-app/page"},
+		{"../other", "http://example.com/old-app/page"},
+	} {
+		var docBase *url.URL
+		if test.docBase != "" {
+			docBase = mustParse(t, test.docBase)
+		}
+		_, err := Rebase(mustParse(t, test.u), docBase, oldBase, newBase)
+		assert.ErrorIs(t, err, ErrNoBase, test.u)
+	}
+}
@@ -0,0 +1,227 @@
+// Package urlrewrite implements ordered pattern-based rules that rewrite URLs.
+//
+// Rules are applied in order, each rule to the result of the previous ones,
+// so that for example a host alias can be combined with a scheme upgrade.
+package urlrewrite
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/martin-sucha/site-to-static/rules"
+	"github.com/martin-sucha/site-to-static/urlnorm"
+	"github.com/martin-sucha/site-to-static/urlrebase"
+)
+
+// ErrNoMatch is returned when no rule matches the URL.
+var ErrNoMatch = errors.New("no rule matches url")
+
+// RuleKind is the kind of rewrite rule.
+type RuleKind uint8
+
+const (
+	// RuleRegexp replaces the first match of Pattern anywhere in the URL with Replacement.
+	RuleRegexp RuleKind = iota
+	// RuleGlob replaces URLs matching Pattern as a whole with Replacement.
+	RuleGlob
+	// RuleHost replaces host From with To.
+	RuleHost
+	// RuleScheme replaces scheme From with To.
+	RuleScheme
+)
+
+var ruleKindNames = [...]string{
+	RuleRegexp: "re",
+	RuleGlob:   "glob",
+	RuleHost:   "host",
+	RuleScheme: "scheme",
+}
+
+func (k RuleKind) String() string {
+	if int(k) < len(ruleKindNames) {
+		return ruleKindNames[k]
+	}
+	return fmt.Sprintf("RuleKind(%d)", k)
+}
+
+// Rule rewrites URLs.
+type Rule struct {
+	Kind RuleKind
+	// Pattern is matched against the canonical absolute URL for RuleRegexp and RuleGlob rules.
+	Pattern *regexp.Regexp
+	// Replacement is the new URL for RuleRegexp and RuleGlob rules.
+	// $1 or ${1} in it is replaced by the text matched by the first capture group, $0 by the whole match.
+	// Each * in glob patterns is a capture group.
+	Replacement string
+	// From is the lowercase host or scheme replaced by RuleHost and RuleScheme rules.
+	// The host includes the port if it is not default for the scheme.
+	From string
+	// To is the new host or scheme.
+	To string
+}
+
+// ParseRule parses a rule in format kind:pattern replacement.
+//
+// Kinds are:
+//
+//   - re:regexp replacement replaces the first match of the regular expression in the URL
+//   - glob:pattern replacement replaces the URL if the pattern matches it as a whole
+//   - host:old new replaces the host
+//   - scheme:old new replaces the scheme
+//
+// In glob patterns, * matches any sequence of characters including / and all other characters match literally.
+// For example "re:/index\.php\?page=(\w+)$ /$1/" or "host:www.example.com example.com".
+func ParseRule(s string) (Rule, error) {
+	var rule Rule
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return rule, fmt.Errorf("rule %q must have format \"kind:pattern replacement\"", s)
+	}
+	idx := strings.IndexByte(fields[0], ':')
+	if idx < 0 {
+		return rule, fmt.Errorf("rule %q: pattern must start with re:, glob:, host: or scheme:", s)
+	}
+	kind, pattern, replacement := fields[0][:idx], fields[0][idx+1:], fields[1]
+	switch kind {
+	case "re":
+		rule.Kind = RuleRegexp
+		var err error
+		rule.Pattern, err = regexp.Compile(pattern)
+		if err != nil {
+			return rule, fmt.Errorf("rule %q: %v", s, err)
+		}
+		rule.Replacement = replacement
+	case "glob":
+		rule.Kind = RuleGlob
+		rule.Pattern = rules.CompileGlob(pattern)
+		rule.Replacement = replacement
+	case "host":
+		rule.Kind = RuleHost
+		rule.From = strings.ToLower(pattern)
+		rule.To = strings.ToLower(replacement)
+	case "scheme":
+		rule.Kind = RuleScheme
+		rule.From = strings.ToLower(strings.TrimSuffix(pattern, "://"))
+		rule.To = strings.ToLower(strings.TrimSuffix(replacement, "://"))
+	default:
+		return rule, fmt.Errorf("rule %q: pattern must start with re:, glob:, host: or scheme:", s)
+	}
+	if pattern == "" {
+		return rule, fmt.Errorf("rule %q: empty pattern", s)
+	}
+	return rule, nil
+}
+
+// ParseRules parses rules from r, one rule per line.
+// Empty lines and lines starting with # are ignored.
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var out []Rule
+	err := rules.ParseLines(r, func(line string) error {
+		rule, err := ParseRule(line)
+		if err != nil {
+			return err
+		}
+		out = append(out, rule)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// apply returns u rewritten by the rule, or nil if the rule does not match.
+func (r *Rule) apply(u *url.URL) (*url.URL, error) {
+	switch r.Kind {
+	case RuleRegexp, RuleGlob:
+		s := u.String()
+		m := r.Pattern.FindStringSubmatchIndex(s)
+		if m == nil {
+			return nil, nil
+		}
+		var dst []byte
+		dst = append(dst, s[:m[0]]...)
+		dst = r.Pattern.ExpandString(dst, r.Replacement, s, m)
+		dst = append(dst, s[m[1]:]...)
+		newURL, err := url.Parse(string(dst))
+		if err != nil {
+			return nil, fmt.Errorf("rule %s:%s: %v", r.Kind, r.Pattern, err)
+		}
+		if !newURL.IsAbs() {
+			return nil, fmt.Errorf("rule %s:%s: rewritten url %q is not absolute", r.Kind, r.Pattern, newURL)
+		}
+		return urlnorm.Canonical(newURL), nil
+	case RuleHost:
+		if u.Host != r.From {
+			return nil, nil
+		}
+		newURL := *u
+		newURL.Host = r.To
+		return urlnorm.Canonical(&newURL), nil
+	case RuleScheme:
+		if u.Scheme != r.From {
+			return nil, nil
+		}
+		newURL := *u
+		newURL.Scheme = r.To
+		return urlnorm.Canonical(&newURL), nil
+	default:
+		return nil, fmt.Errorf("unknown rule kind %s", r.Kind)
+	}
+}
+
+// Rewriter applies an ordered list of rules.
+type Rewriter struct {
+	Rules []Rule
+}
+
+// Rewrite returns u rewritten by the rules.
+// ErrNoMatch is returned if no rule matches.
+//
+// If u is a relative reference, it is resolved against docBase, the base URL of the document containing it,
+// and the result is made relative to the rewritten docBase in the same form as u, see urlrebase.SameForm.
+// docBase may be nil if u is absolute.
+func (rw *Rewriter) Rewrite(u, docBase *url.URL) (*url.URL, error) {
+	if u.IsAbs() {
+		return rw.rewriteAbsolute(u)
+	}
+	if docBase == nil {
+		return nil, ErrNoMatch
+	}
+	rewritten, err := rw.rewriteAbsolute(docBase.ResolveReference(u))
+	if err != nil {
+		return nil, err
+	}
+	// The document is rewritten by the same rules as the links.
+	newDocBase, err := rw.rewriteAbsolute(docBase)
+	switch {
+	case errors.Is(err, ErrNoMatch):
+		newDocBase = urlnorm.Canonical(docBase)
+	case err != nil:
+		return nil, err
+	}
+	return urlrebase.SameForm(u, rewritten, newDocBase), nil
+}
+
+func (rw *Rewriter) rewriteAbsolute(u *url.URL) (*url.URL, error) {
+	u = urlnorm.Canonical(u)
+	matched := false
+	for i := range rw.Rules {
+		newURL, err := rw.Rules[i].apply(u)
+		if err != nil {
+			return nil, err
+		}
+		if newURL != nil {
+			u = newURL
+			matched = true
+		}
+	}
+	if !matched {
+		return nil, ErrNoMatch
+	}
+	return u, nil
+}
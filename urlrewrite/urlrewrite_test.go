@@ -0,0 +1,109 @@
+package urlrewrite
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParse(t *testing.T, s string) *url.URL {
+	u, err := url.Parse(s)
+	require.NoError(t, err)
+	return u
+}
+
+func TestParseRule(t *testing.T) {
+	rule, err := ParseRule(`re:/index\.php\?page=(\w+)$ /$1/`)
+	require.NoError(t, err)
+	assert.Equal(t, RuleRegexp, rule.Kind)
+	assert.Equal(t, "/$1/", rule.Replacement)
+
+	rule, err = ParseRule("glob:http://example.com/*.aspx https://example.com/$1.html")
+	require.NoError(t, err)
+	assert.Equal(t, RuleGlob, rule.Kind)
+	assert.True(t, rule.Pattern.MatchString("http://example.com/a/b.aspx"))
+	assert.False(t, rule.Pattern.MatchString("http://example.com/a/b.aspx?x=1"))
+
+	rule, err = ParseRule("host:WWW.example.com example.com")
+	require.NoError(t, err)
+	assert.Equal(t, Rule{Kind: RuleHost, From: "www.example.com", To: "example.com"}, rule)
+
+	rule, err = ParseRule("scheme:http:// https://")
+	require.NoError(t, err)
+	assert.Equal(t, Rule{Kind: RuleScheme, From: "http", To: "https"}, rule)
+
+	for _, s := range []string{"", "re:a", "re:a b c", "a b", "path:a b", "re:( b", "host: b"} {
+		_, err = ParseRule(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader("# comment\n\nhost:www.example.com example.com\nscheme:http https\n"))
+	require.NoError(t, err)
+	assert.Len(t, rules, 2)
+
+	_, err = ParseRules(strings.NewReader("scheme:http https\nhost:example.com\n"))
+	assert.EqualError(t, err, `line 2: rule "host:example.com" must have format "kind:pattern replacement"`)
+}
+
+func TestRewriter_Rewrite(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(`
+re:/index\.php\?page=(\w+)$ /$1/
+glob:http://old.example.com/*/*.aspx http://example.com/${2}/${1}.html
+host:www.example.com example.com
+scheme:http https
+`))
+	require.NoError(t, err)
+	rw := &Rewriter{Rules: rules}
+	tests := []struct {
+		u, docBase, expected string
+	}{
+		{"http://www.example.com/index.php?page=about", "", "https://example.com/about/"},
+		{"HTTP://WWW.Example.com:80/a", "", "https://example.com/a"},
+		{"http://old.example.com/blog/post.aspx", "", "https://example.com/post/blog.html"},
+		{"http://other.example.com/a#x", "", "https://other.example.com/a#x"},
+		{"/index.php?page=contact", "http://www.example.com/a/b", "/contact/"},
+		{"c/d", "http://www.example.com/a/b", "c/d"},
+		{"#top", "http://www.example.com/a/b", "#top"},
+		{"//www.example.com/a", "http://www.example.com/a/b", "//example.com/a"},
+	}
+	for _, test := range tests {
+		var docBase *url.URL
+		if test.docBase != "" {
+			docBase = mustParse(t, test.docBase)
+		}
+		rewritten, err := rw.Rewrite(mustParse(t, test.u), docBase)
+		if assert.NoError(t, err, test.u) {
+			assert.Equal(t, test.expected, rewritten.String(), test.u)
+		}
+	}
+}
+
+func TestRewriter_NoMatch(t *testing.T) {
+	rw := &Rewriter{Rules: []Rule{{Kind: RuleHost, From: "www.example.com", To: "example.com"}}}
+	_, err := rw.Rewrite(mustParse(t, "http://example.com/a"), nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+	_, err = rw.Rewrite(mustParse(t, "/a"), nil)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	// Links between documents that are rewritten together keep their form.
+	rewritten, err := rw.Rewrite(mustParse(t, "/a"), mustParse(t, "http://www.example.com/b"))
+	require.NoError(t, err)
+	assert.Equal(t, "/a", rewritten.String())
+	// Links from documents that are not rewritten point to the new URL.
+	rewritten, err = rw.Rewrite(mustParse(t, "//www.example.com/a"), mustParse(t, "http://example.org/"))
+	require.NoError(t, err)
+	assert.Equal(t, "//example.com/a", rewritten.String())
+}
+
+func TestRewriter_Invalid(t *testing.T) {
+	rule, err := ParseRule("re:^http://example.com/ /")
+	require.NoError(t, err)
+	rw := &Rewriter{Rules: []Rule{rule}}
+	_, err = rw.Rewrite(mustParse(t, "http://example.com/a"), nil)
+	assert.Error(t, err)
+}